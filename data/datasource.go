@@ -7,13 +7,18 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/afero"
 
 	"github.com/pkg/errors"
 
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/hairyhenderson/gomplate/v3/libkv"
 	"github.com/hairyhenderson/gomplate/v3/vault"
 )
@@ -35,57 +40,120 @@ func init() {
 	regExtension(".env", envMimetype)
 }
 
-// registerReaders registers the source-reader functions
-func (d *Data) registerReaders() {
-	d.sourceReaders = make(map[string]func(*Source, ...string) ([]byte, error))
-
-	d.sourceReaders["aws+smp"] = readAWSSMP
-	d.sourceReaders["aws+sm"] = readAWSSecretsManager
-	d.sourceReaders["boltdb"] = readBoltDB
-	d.sourceReaders["consul"] = readConsul
-	d.sourceReaders["consul+http"] = readConsul
-	d.sourceReaders["consul+https"] = readConsul
-	d.sourceReaders["env"] = readEnv
-	d.sourceReaders["file"] = readFile
-	d.sourceReaders["http"] = readHTTP
-	d.sourceReaders["https"] = readHTTP
-	d.sourceReaders["merge"] = d.readMerge
-	d.sourceReaders["stdin"] = readStdin
-	d.sourceReaders["vault"] = readVault
-	d.sourceReaders["vault+http"] = readVault
-	d.sourceReaders["vault+https"] = readVault
-	d.sourceReaders["s3"] = readBlob
-	d.sourceReaders["gs"] = readBlob
-	d.sourceReaders["git"] = readGit
-	d.sourceReaders["git+file"] = readGit
-	d.sourceReaders["git+http"] = readGit
-	d.sourceReaders["git+https"] = readGit
-	d.sourceReaders["git+ssh"] = readGit
-}
-
-// lookupReader - return the reader function for the given scheme
-func (d *Data) lookupReader(scheme string) (func(*Source, ...string) ([]byte, error), error) {
-	if d.sourceReaders == nil {
-		d.registerReaders()
-	}
-	r, ok := d.sourceReaders[scheme]
+// Reader - reads the data for a datasource. The media type of the data is
+// returned when the reader knows it, otherwise it's inferred from the URL.
+type Reader interface {
+	Read(ctx context.Context, source *Source, args ...string) (data []byte, mediaType string, err error)
+}
+
+// ReaderFunc - adapts an ordinary function to a Reader
+type ReaderFunc func(ctx context.Context, source *Source, args ...string) ([]byte, string, error)
+
+// Read - calls f(ctx, source, args...)
+func (f ReaderFunc) Read(ctx context.Context, source *Source, args ...string) ([]byte, string, error) {
+	return f(ctx, source, args...)
+}
+
+var (
+	readersMu sync.RWMutex
+	readers   = map[string]Reader{}
+)
+
+// RegisterReader - register a Reader for datasources with the given URL
+// scheme. Registering a scheme again replaces its reader, so built-in readers
+// can be overridden. The 'merge' scheme is reserved.
+func RegisterReader(scheme string, r Reader) {
+	if scheme == "" || scheme == "merge" {
+		panic(fmt.Sprintf("data: can't register a reader for scheme %q", scheme))
+	}
+	if r == nil {
+		panic("data: RegisterReader reader is nil for scheme " + scheme)
+	}
+	readersMu.Lock()
+	defer readersMu.Unlock()
+	readers[scheme] = r
+}
+
+func init() {
+	for scheme, r := range map[string]ReaderFunc{
+		"aws+smp":      readAWSSMP,
+		"aws+sm":       readAWSSecretsManager,
+		"boltdb":       readBoltDB,
+		"consul":       readConsul,
+		"consul+http":  readConsul,
+		"consul+https": readConsul,
+		"env":          readEnv,
+		"file":         readFile,
+		"http":         readHTTP,
+		"https":        readHTTP,
+		"stdin":        readStdin,
+		"vault":        readVault,
+		"vault+http":   readVault,
+		"vault+https":  readVault,
+		"s3":           readBlob,
+		"gs":           readBlob,
+		"git":          readGit,
+		"git+file":     readGit,
+		"git+http":     readGit,
+		"git+https":    readGit,
+		"git+ssh":      readGit,
+	} {
+		RegisterReader(scheme, r)
+	}
+}
+
+// lookupReader - return the reader for the given scheme
+func (d *Data) lookupReader(scheme string) (Reader, error) {
+	// merge: sources read other datasources, so need the Data
+	if scheme == "merge" {
+		return ReaderFunc(d.readMerge), nil
+	}
+	readersMu.RLock()
+	defer readersMu.RUnlock()
+	r, ok := readers[scheme]
 	if !ok {
 		return nil, errors.Errorf("scheme %s not registered", scheme)
 	}
 	return r, nil
 }
 
+// sourceReader - return the reader for the source. Overrides can also give
+// their value inline, as a data: URL.
+func (d *Data) sourceReader(source *Source) (Reader, error) {
+	if source.origURL != nil && source.URL.Scheme == "data" {
+		return ReaderFunc(readData), nil
+	}
+	return d.lookupReader(source.URL.Scheme)
+}
+
+// SupportedScheme - whether datasources with the given URL scheme can be read
+func SupportedScheme(scheme string) bool {
+	d := &Data{}
+	_, err := d.lookupReader(scheme)
+	return err == nil
+}
+
 // Data -
 type Data struct {
 	ctx context.Context
 
 	Sources map[string]*Source
 
-	sourceReaders map[string]func(*Source, ...string) ([]byte, error)
-	cache         map[string][]byte
+	cache map[string][]byte
+
+	// caches remote datasources on disk between runs, nil if not enabled
+	diskCache *diskCache
+
+	// default HTTP client options, for sources which don't set their own
+	httpDefaults config.HTTPConfig
 
 	// headers from the --datasource-header/-H option that don't reference datasources from the commandline
 	extraHeaders map[string]http.Header
+
+	// replacement URLs from the --datasource-override option, and whether
+	// they've been read from yet
+	overrides     map[string]config.DataSource
+	overridesUsed map[string]bool
 }
 
 // Cleanup - clean up datasources before shutting the process down - things
@@ -115,25 +183,124 @@ func FromConfig(ctx context.Context, cfg *config.Config) *Data {
 	stdin = cfg.Stdin
 
 	sources := map[string]*Source{}
-	for alias, d := range cfg.DataSources {
-		sources[alias] = &Source{
-			Alias:  alias,
-			URL:    d.URL,
-			header: d.Header,
+	d := &Data{
+		ctx:          ctx,
+		Sources:      sources,
+		extraHeaders: cfg.ExtraHeaders,
+		overrides:    cfg.DataSourceOverrides,
+		httpDefaults: cfg.HTTP,
+	}
+	for alias, ds := range cfg.DataSources {
+		sources[alias] = d.newSource(alias, ds)
+	}
+	for alias, ds := range cfg.Context {
+		sources[alias] = d.newSource(alias, ds)
+	}
+	if cfg.CacheDir != "" {
+		d.diskCache = &diskCache{
+			fs:      afero.NewOsFs(),
+			dir:     cfg.CacheDir,
+			ttl:     cfg.CacheTTL,
+			offline: cfg.Offline,
 		}
 	}
-	for alias, d := range cfg.Context {
-		sources[alias] = &Source{
-			Alias:  alias,
-			URL:    d.URL,
-			header: d.Header,
+	for _, s := range sources {
+		d.applyOverride(s)
+	}
+	return d
+}
+
+// AddSources - define the given datasources, replacing any already defined with
+// the same alias. Sources which are unchanged are kept, along with their cached
+// data and clients, so that they can be shared between runs.
+func (d *Data) AddSources(sources map[string]config.DataSource) {
+	if d.Sources == nil {
+		d.Sources = map[string]*Source{}
+	}
+	for alias, ds := range sources {
+		ns := d.newSource(alias, ds)
+		if s, ok := d.Sources[alias]; ok {
+			// overridden sources are read from the override regardless
+			if s.origURL != nil && s.origURL.String() == ns.URL.String() {
+				continue
+			}
+			if s.URL.String() == ns.URL.String() && reflect.DeepEqual(s.header, ns.header) &&
+				s.cacheTTL == ns.cacheTTL && s.cacheSecrets == ns.cacheSecrets &&
+				s.httpConfig == ns.httpConfig {
+				continue
+			}
+			s.cleanup()
+			// cached data is keyed by alias, so it's now stale
+			d.cache = nil
 		}
+		d.applyOverride(ns)
+		d.Sources[alias] = ns
 	}
-	return &Data{
-		ctx:          ctx,
-		Sources:      sources,
-		extraHeaders: cfg.ExtraHeaders,
+}
+
+// applyOverride - replace the source's URL (and headers, if set) with the
+// override configured for its alias, if any. The original URL is kept so that
+// its MIME type hints still apply.
+func (d *Data) applyOverride(s *Source) {
+	o, ok := d.overrides[s.Alias]
+	if !ok || s.origURL != nil {
+		return
+	}
+	s.origURL = s.URL
+	s.URL = o.URL
+	if o.Header != nil {
+		s.header = o.Header
+	}
+	if o.CacheTTL != 0 {
+		s.cacheTTL = o.CacheTTL
+	}
+	s.httpConfig = s.httpConfig.MergeFrom(o.HTTP)
+}
+
+// SetOverrides - replace the datasource overrides, for example with those of
+// the next job. Sources whose override changed are removed, and must be added
+// again with AddSources. Replaced overrides are still checked by
+// VerifyOverrides.
+func (d *Data) SetOverrides(overrides map[string]config.DataSource) {
+	if d.overridesUsed == nil {
+		d.overridesUsed = make(map[string]bool)
+	}
+	for alias := range d.overrides {
+		d.overridesUsed[alias] = d.overridesUsed[alias]
+	}
+	for alias, s := range d.Sources {
+		o, ok := overrides[alias]
+		prev, wasOK := d.overrides[alias]
+		if ok == wasOK && reflect.DeepEqual(o, prev) {
+			continue
+		}
+		s.cleanup()
+		delete(d.Sources, alias)
+		// cached data is keyed by alias, so it's now stale
+		d.cache = nil
+	}
+	d.overrides = overrides
+}
+
+// VerifyOverrides - returns an error if any datasource overrides reference
+// aliases that were never read from. Intended to be called after rendering.
+func (d *Data) VerifyOverrides() error {
+	unused := []string{}
+	for alias, used := range d.overridesUsed {
+		if _, ok := d.overrides[alias]; !ok && !used {
+			unused = append(unused, alias)
+		}
+	}
+	for alias := range d.overrides {
+		if !d.overridesUsed[alias] {
+			unused = append(unused, alias)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return errors.Errorf("datasource override(s) given for unused alias(es): %s", strings.Join(unused, ", "))
 	}
+	return nil
 }
 
 // Source - a data source
@@ -146,10 +313,28 @@ type Source struct {
 	asmpg             awssmpGetter            // used for aws+smp:, nil otherwise
 	awsSecretsManager awsSecretsManagerGetter // used for aws+sm, nil otherwise
 	header            http.Header             // used for http[s]: URLs, nil otherwise
+	origURL           *url.URL                // the URL before it was overridden, nil otherwise
+	cacheTTL          time.Duration           // how long to cache on disk for, 0 for the default
+	cacheSecrets      bool                    // whether to cache on disk even if the source may hold secrets
+	httpConfig        config.HTTPConfig       // HTTP client options, used for http[s]: URLs
 	Alias             string
 	mediaType         string
 }
 
+func (d *Data) newSource(alias string, ds config.DataSource) *Source {
+	s := &Source{
+		Alias:        alias,
+		URL:          ds.URL,
+		header:       ds.Header,
+		cacheTTL:     ds.CacheTTL,
+		cacheSecrets: ds.CacheSecrets,
+		httpConfig:   d.httpDefaults.MergeFrom(ds.HTTP),
+	}
+	// an invalid parameter is left in place, and reported when read
+	_ = s.applyCacheTTLParam()
+	return s
+}
+
 func (s *Source) inherit(parent *Source) {
 	s.fs = parent.fs
 	s.hc = parent.hc
@@ -176,6 +361,9 @@ func (s *Source) cleanup() {
 // 2. otherwise, the Type property on the Source is used, if present
 // 3. otherwise, a MIME type is calculated from the file extension, if the extension is registered
 // 4. otherwise, the default type of 'text/plain' is used
+//
+// When the Source's URL has been overridden, the original URL's 'type' query
+// parameter and file extension are consulted after the current URL's.
 func (s *Source) mimeType(arg string) (mimeType string, err error) {
 	if len(arg) > 0 {
 		if strings.HasPrefix(arg, "//") {
@@ -193,6 +381,9 @@ func (s *Source) mimeType(arg string) (mimeType string, err error) {
 	if mediatype == "" {
 		mediatype = s.URL.Query().Get("type")
 	}
+	if mediatype == "" && s.origURL != nil {
+		mediatype = s.origURL.Query().Get("type")
+	}
 
 	if mediatype == "" {
 		mediatype = s.mediaType
@@ -211,6 +402,11 @@ func (s *Source) mimeType(arg string) (mimeType string, err error) {
 		mediatype = mime.TypeByExtension(ext)
 	}
 
+	if mediatype == "" && s.origURL != nil {
+		ext := filepath.Ext(s.origURL.Path)
+		mediatype = mime.TypeByExtension(ext)
+	}
+
 	if mediatype != "" {
 		t, _, err := mime.ParseMediaType(mediatype)
 		if err != nil {
@@ -223,6 +419,22 @@ func (s *Source) mimeType(arg string) (mimeType string, err error) {
 	return textMimetype, nil
 }
 
+// Header - the headers configured for the datasource, for readers that send
+// them on with their requests. The returned header is a copy.
+func (s *Source) Header() http.Header {
+	return s.header.Clone()
+}
+
+// MediaType - the MIME type the datasource's URL hints at, with its 'type'
+// query parameter or its file extension, or text/plain if there's no hint
+func (s *Source) MediaType() string {
+	mediaType, err := s.mimeType("")
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
 // String is the method to format the flag's value, part of the flag.Value interface.
 // The String method's output will be used in diagnostics.
 func (s *Source) String() string {
@@ -241,11 +453,8 @@ func (d *Data) DefineDatasource(alias, value string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	s := &Source{
-		Alias:  alias,
-		URL:    srcURL,
-		header: d.extraHeaders[alias],
-	}
+	s := d.newSource(alias, config.DataSource{URL: srcURL, Header: d.extraHeaders[alias]})
+	d.applyOverride(s)
 	if d.Sources == nil {
 		d.Sources = make(map[string]*Source)
 	}
@@ -266,11 +475,7 @@ func (d *Data) lookupSource(alias string) (*Source, error) {
 		if err != nil || !srcURL.IsAbs() {
 			return nil, errors.Errorf("Undefined datasource '%s'", alias)
 		}
-		source = &Source{
-			Alias:  alias,
-			URL:    srcURL,
-			header: d.extraHeaders[alias],
-		}
+		source = d.newSource(alias, config.DataSource{URL: srcURL, Header: d.extraHeaders[alias]})
 		d.Sources[alias] = source
 	}
 	if source.Alias == "" {
@@ -290,7 +495,8 @@ func (d *Data) readDataSource(alias string, args ...string) (data, mimeType stri
 	}
 
 	subpath := ""
-	if len(args) > 0 {
+	// templated request bodies use the args instead
+	if len(args) > 0 && !source.httpConfig.TemplateBody {
 		subpath = args[0]
 	}
 	mimeType, err = source.mimeType(subpath)
@@ -363,6 +569,12 @@ func (d *Data) readSource(source *Source, args ...string) ([]byte, error) {
 	if d.cache == nil {
 		d.cache = make(map[string][]byte)
 	}
+	if source.origURL != nil {
+		if d.overridesUsed == nil {
+			d.overridesUsed = make(map[string]bool)
+		}
+		d.overridesUsed[source.Alias] = true
+	}
 	cacheKey := source.Alias
 	for _, v := range args {
 		cacheKey += v
@@ -371,14 +583,26 @@ func (d *Data) readSource(source *Source, args ...string) ([]byte, error) {
 	if ok {
 		return cached, nil
 	}
-	r, err := d.lookupReader(source.URL.Scheme)
+	err := source.applyCacheTTLParam()
+	if err != nil {
+		return nil, err
+	}
+	redact.AddURL(source.URL)
+	r, err := d.sourceReader(source)
 	if err != nil {
 		return nil, errors.Wrap(err, "Datasource not yet supported")
 	}
-	data, err := r(source, args...)
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	data, mediaType, err := d.readCached(ctx, r, source, args...)
 	if err != nil {
 		return nil, err
 	}
+	if mediaType != "" {
+		source.mediaType = mediaType
+	}
 	d.cache[cacheKey] = data
 	return data, nil
 }
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
@@ -11,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 
 	gaws "github.com/hairyhenderson/gomplate/v3/aws"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 )
 
 // awsSecretsManagerGetter - A subset of Secrets Manager API for use in unit testing
@@ -55,17 +57,18 @@ func parseDatasourceURLArgs(sourceURL *url.URL, args ...string) (params map[stri
 	return params, p, nil
 }
 
-func readAWSSecretsManager(source *Source, args ...string) (output []byte, err error) {
+func readAWSSecretsManager(_ context.Context, source *Source, args ...string) (output []byte, mediaType string, err error) {
 	if source.awsSecretsManager == nil {
 		source.awsSecretsManager = secretsmanager.New(gaws.SDKSession())
 	}
 
 	_, paramPath, err := parseDatasourceURLArgs(source.URL, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return readAWSSecretsManagerParam(source, paramPath)
+	output, err = readAWSSecretsManagerParam(source, paramPath)
+	return output, "", err
 }
 
 func readAWSSecretsManagerParam(source *Source, paramPath string) ([]byte, error) {
@@ -78,5 +81,10 @@ func readAWSSecretsManagerParam(source *Source, paramPath string) ([]byte, error
 		return nil, errors.Wrapf(err, "Error reading aws+sm from AWS using GetSecretValue with input %v", input)
 	}
 
+	// secrets are often JSON objects of key/value pairs, in which case the
+	// values are tracked individually, since they're likely to be used alone
+	redact.Add(*response.SecretString)
+	redact.AddJSON([]byte(*response.SecretString))
+
 	return []byte(*response.SecretString), nil
 }
@@ -1,12 +1,14 @@
 package data
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -107,7 +109,7 @@ func TestAWSSecretsManager_GetParameterSetup(t *testing.T) {
 		},
 	})
 
-	_, err := readAWSSecretsManager(s, "/bar")
+	_, _, err := readAWSSecretsManager(context.Background(), s, "/bar")
 	assert.True(t, calledOk)
 	assert.Nil(t, err)
 }
@@ -123,7 +125,7 @@ func TestAWSSecretsManager_GetParameterSetupWrongArgs(t *testing.T) {
 		},
 	})
 
-	_, err := readAWSSecretsManager(s, "/bar", "/foo", "/bla")
+	_, _, err := readAWSSecretsManager(context.Background(), s, "/bar", "/foo", "/bla")
 	assert.False(t, calledOk)
 	assert.Error(t, err)
 }
@@ -135,7 +137,7 @@ func TestAWSSecretsManager_GetParameterMissing(t *testing.T) {
 		err: expectedErr,
 	})
 
-	_, err := readAWSSecretsManager(s, "")
+	_, _, err := readAWSSecretsManager(context.Background(), s, "")
 	assert.Error(t, err, "Test of error message")
 }
 
@@ -155,3 +157,16 @@ func TestAWSSecretsManager_ReadSecret(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("blub"), output)
 }
+
+func TestAWSSecretsManager_ReadSecretRedacted(t *testing.T) {
+	s := simpleAWSSecretsManagerSourceHelper(DummyAWSSecretsManagerSecretGetter{
+		t: t,
+		secretValut: &secretsmanager.GetSecretValueOutput{
+			SecretString: aws.String(`{"user": "smuser", "password": "smpassword"}`),
+		},
+	})
+
+	_, err := readAWSSecretsManagerParam(s, "/foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "user **** has password ****", redact.String("user smuser has password smpassword"))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 
 	gaws "github.com/hairyhenderson/gomplate/v3/aws"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 )
 
 // awssmpGetter - A subset of SSM API for use in unit testing
@@ -18,26 +19,25 @@ type awssmpGetter interface {
 	GetParametersByPathWithContext(ctx context.Context, input *ssm.GetParametersByPathInput, opts ...request.Option) (*ssm.GetParametersByPathOutput, error)
 }
 
-func readAWSSMP(source *Source, args ...string) (data []byte, err error) {
-	ctx := context.TODO()
+func readAWSSMP(ctx context.Context, source *Source, args ...string) (data []byte, mediaType string, err error) {
 	if source.asmpg == nil {
 		source.asmpg = ssm.New(gaws.SDKSession())
 	}
 
 	_, paramPath, err := parseDatasourceURLArgs(source.URL, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	source.mediaType = jsonMimetype
+	mediaType = jsonMimetype
 	switch {
 	case strings.HasSuffix(paramPath, "/"):
-		source.mediaType = jsonArrayMimetype
+		mediaType = jsonArrayMimetype
 		data, err = listAWSSMPParams(ctx, source, paramPath)
 	default:
 		data, err = readAWSSMPParam(ctx, source, paramPath)
 	}
-	return data, err
+	return data, mediaType, err
 }
 
 func readAWSSMPParam(ctx context.Context, source *Source, paramPath string) ([]byte, error) {
@@ -53,6 +53,9 @@ func readAWSSMPParam(ctx context.Context, source *Source, paramPath string) ([]b
 	}
 
 	result := *response.Parameter
+	if result.Value != nil {
+		redact.Add(*result.Value)
+	}
 
 	output, err := ToJSON(result)
 	return []byte(output), err
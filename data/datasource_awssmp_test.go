@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,7 +72,7 @@ func TestAWSSMP_GetParameterSetup(t *testing.T) {
 		},
 	})
 
-	_, err := readAWSSMP(s, "/bar")
+	_, _, err := readAWSSMP(context.Background(), s, "/bar")
 	assert.True(t, calledOk)
 	assert.Nil(t, err)
 }
@@ -88,13 +89,13 @@ func TestAWSSMP_GetParameterValidOutput(t *testing.T) {
 		param: expected,
 	})
 
-	output, err := readAWSSMP(s, "")
+	output, mediaType, err := readAWSSMP(context.Background(), s, "")
 	assert.Nil(t, err)
 	actual := &ssm.Parameter{}
 	err = json.Unmarshal(output, &actual)
 	assert.Nil(t, err)
 	assert.Equal(t, expected, actual)
-	assert.Equal(t, jsonMimetype, s.mediaType)
+	assert.Equal(t, jsonMimetype, mediaType)
 }
 
 func TestAWSSMP_GetParameterMissing(t *testing.T) {
@@ -104,7 +105,7 @@ func TestAWSSMP_GetParameterMissing(t *testing.T) {
 		err: expectedErr,
 	})
 
-	_, err := readAWSSMP(s, "")
+	_, _, err := readAWSSMP(context.Background(), s, "")
 	assert.Error(t, err, "Test of error message")
 }
 
@@ -141,3 +142,18 @@ func TestAWSSMP_listAWSSMPParams(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(`["a","b","c"]`), data)
 }
+
+func TestAWSSMP_GetParameterRedacted(t *testing.T) {
+	s := simpleAWSSourceHelper(DummyParamGetter{
+		t: t,
+		param: &ssm.Parameter{
+			Name:  aws.String("/smp/param"),
+			Type:  aws.String("SecureString"),
+			Value: aws.String("smpsecret"),
+		},
+	})
+
+	_, _, err := readAWSSMP(context.Background(), s, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "/smp/param is ****", redact.String("/smp/param is smpsecret"))
+}
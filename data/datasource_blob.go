@@ -20,13 +20,11 @@ import (
 	"gocloud.dev/gcp"
 )
 
-func readBlob(source *Source, args ...string) (output []byte, err error) {
+func readBlob(ctx context.Context, source *Source, args ...string) (output []byte, mediaType string, err error) {
 	if len(args) >= 2 {
-		return nil, errors.New("maximum two arguments to blob datasource: alias, extraPath")
+		return nil, "", errors.New("maximum two arguments to blob datasource: alias, extraPath")
 	}
 
-	ctx := context.TODO()
-
 	key := source.URL.Path
 	if len(args) == 1 {
 		key = path.Join(key, args[0])
@@ -34,7 +32,7 @@ func readBlob(source *Source, args ...string) (output []byte, err error) {
 
 	opener, err := newOpener(ctx, source.URL)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	mux := blob.URLMux{}
@@ -43,7 +41,7 @@ func readBlob(source *Source, args ...string) (output []byte, err error) {
 	u := blobURL(source.URL)
 	bucket, err := mux.OpenBucket(ctx, u)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer bucket.Close()
 
@@ -54,11 +52,8 @@ func readBlob(source *Source, args ...string) (output []byte, err error) {
 		r = getBlob
 	}
 
-	mediaType, data, err := r(ctx, bucket, key)
-	if mediaType != "" {
-		source.mediaType = mediaType
-	}
-	return data, err
+	mediaType, output, err = r(ctx, bucket, key)
+	return output, mediaType, err
 }
 
 // create the correct kind of blob.BucketURLOpener for the given URL
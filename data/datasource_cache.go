@@ -0,0 +1,284 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hairyhenderson/gomplate/v3/env"
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spf13/afero"
+)
+
+// cacheTTLParam - the URL query parameter which sets a datasource's cache TTL.
+// It's removed from the URL before the datasource is read.
+const cacheTTLParam = "cacheTTL"
+
+// localSchemes - schemes which are read without the network, and so aren't
+// worth caching, and can be read offline
+var localSchemes = map[string]bool{
+	"boltdb":   true,
+	"data":     true,
+	"env":      true,
+	"file":     true,
+	"git+file": true,
+	"merge":    true,
+	"stdin":    true,
+}
+
+// secretSchemes - schemes which are likely to hold secrets, and so are only
+// cached when the datasource opts in
+var secretSchemes = map[string]bool{
+	"aws+sm":      true,
+	"aws+smp":     true,
+	"vault":       true,
+	"vault+http":  true,
+	"vault+https": true,
+}
+
+// diskCache - caches datasource data on disk, so it can be shared between
+// runs
+type diskCache struct {
+	fs  afero.Fs
+	dir string
+	// the TTL for datasources which don't set their own
+	ttl     time.Duration
+	offline bool
+}
+
+type cacheEntry struct {
+	Fetched   time.Time `json:"fetched"`
+	MediaType string    `json:"mediaType,omitempty"`
+	Data      []byte    `json:"data"`
+}
+
+// splitCacheTTL - returns a copy of the URL without the cacheTTL parameter,
+// and the parameter's value. The URL is returned as-is if it isn't set.
+func splitCacheTTL(u *url.URL) (*url.URL, string) {
+	if u == nil {
+		return u, ""
+	}
+	q := u.Query()
+	v := q.Get(cacheTTLParam)
+	if v == "" {
+		return u, ""
+	}
+	q.Del(cacheTTLParam)
+	out := cloneURL(u)
+	out.RawQuery = q.Encode()
+	return out, v
+}
+
+// applyCacheTTLParam - set the source's cache TTL from its URL, if given, and
+// remove the parameter so it isn't sent on to the datasource
+func (s *Source) applyCacheTTLParam() error {
+	u, v := splitCacheTTL(s.URL)
+	if v == "" {
+		return nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s parameter for datasource %s", cacheTTLParam, s.Alias)
+	}
+	s.URL = u
+	s.cacheTTL = ttl
+	return nil
+}
+
+// cacheable - whether the source can be cached at all
+func (c *diskCache) cacheable(source *Source) bool {
+	scheme := source.URL.Scheme
+	if localSchemes[scheme] {
+		return false
+	}
+	return !secretSchemes[scheme] || source.cacheSecrets
+}
+
+// credentialEnvVars - the environment variables holding the credentials used
+// to read sources with the given scheme
+func credentialEnvVars(scheme string) []string {
+	switch {
+	case scheme == "s3" || strings.HasPrefix(scheme, "aws+"):
+		return []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE"}
+	case scheme == "gs":
+		return []string{"GOOGLE_APPLICATION_CREDENTIALS"}
+	case strings.HasPrefix(scheme, "vault"):
+		return []string{
+			"VAULT_TOKEN", "VAULT_ROLE_ID", "VAULT_SECRET_ID", "VAULT_APP_ID",
+			"VAULT_USER_ID", "VAULT_AUTH_USERNAME", "VAULT_AUTH_PASSWORD",
+			"VAULT_AUTH_GITHUB_TOKEN", "VAULT_AUTH_AWS_ROLE",
+		}
+	case strings.HasPrefix(scheme, "consul"):
+		return []string{"CONSUL_HTTP_TOKEN", "CONSUL_HTTP_AUTH"}
+	case strings.HasPrefix(scheme, "git"):
+		return []string{"GIT_HTTP_PASSWORD", "GIT_HTTP_TOKEN", "GIT_SSH_KEY"}
+	}
+	return nil
+}
+
+// key - the name of the cache entry for reading the source with the given
+// args. Headers, the HTTP request, and pagination are included, since they may change the
+// response, as are the credentials used, so that sources read with different
+// credentials don't share entries. Only the hash is stored, so credentials
+// aren't written to disk.
+func (c *diskCache) key(source *Source, args []string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", source.URL)
+	if hc := source.httpConfig; hc.Method != "" || hc.Body != "" || hc.BodyFile != "" {
+		fmt.Fprintf(h, "%s %q %q %t\n", hc.Method, hc.Body, hc.BodyFile, hc.TemplateBody)
+	}
+	if p := source.httpConfig.Paginate; p != (config.PaginateConfig{}) {
+		fmt.Fprintf(h, "%+v\n", p)
+	}
+	for _, a := range args {
+		fmt.Fprintf(h, "%s\n", a)
+	}
+	names := make([]string, 0, len(source.header))
+	for name := range source.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s: %q\n", name, source.header[name])
+	}
+	// credentials in the URL (userinfo) are already included
+	if hc := source.httpConfig; hc.ClientCert != "" || hc.ClientKey != "" {
+		fmt.Fprintf(h, "cert %q %q\n", hc.ClientCert, hc.ClientKey)
+	}
+	for _, name := range credentialEnvVars(source.URL.Scheme) {
+		if v := env.Getenv(name); v != "" {
+			fmt.Fprintf(h, "%s=%q\n", name, v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *diskCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// get - the cache entry with the given key, or nil if there isn't one
+func (c *diskCache) get(key string) (*cacheEntry, error) {
+	b, err := afero.ReadFile(c.fs, c.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read datasource cache")
+	}
+	e := &cacheEntry{}
+	err = json.Unmarshal(b, e)
+	if err != nil {
+		// a corrupt entry is as good as a missing one
+		return nil, nil
+	}
+	return e, nil
+}
+
+// put - write the cache entry, replacing any with the same key. Entries are
+// only readable by the current user, since datasources may be sensitive.
+func (c *diskCache) put(key string, e *cacheEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	err = c.fs.MkdirAll(c.dir, 0700)
+	if err != nil {
+		return err
+	}
+	f, err := afero.TempFile(c.fs, c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = c.fs.Chmod(f.Name(), 0600)
+	}
+	if err == nil {
+		err = c.fs.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		_ = c.fs.Remove(f.Name())
+	}
+	return err
+}
+
+// redactCached - register the secrets in a cached entry from a secret-bearing
+// datasource, in the same way as its reader does when reading it
+func redactCached(scheme string, e *cacheEntry) {
+	switch scheme {
+	case "vault", "vault+http", "vault+https":
+		// listings are just key names
+		if e.MediaType != jsonArrayMimetype {
+			redact.AddJSON(e.Data)
+		}
+	case "aws+sm":
+		redact.Add(string(e.Data))
+		redact.AddJSON(e.Data)
+	case "aws+smp":
+		// parameters are cached as JSON objects, and listings as arrays of
+		// names
+		param := struct{ Value *string }{}
+		if json.Unmarshal(e.Data, &param) == nil && param.Value != nil {
+			redact.Add(*param.Value)
+		}
+	}
+}
+
+// readCached - read the source with the reader, unless it's cached on disk
+// and hasn't expired. Offline, cacheable sources are only read from the
+// cache, however old.
+func (d *Data) readCached(ctx context.Context, r Reader, source *Source, args ...string) ([]byte, string, error) {
+	c := d.diskCache
+	if c == nil || !c.cacheable(source) {
+		if c != nil && c.offline && !localSchemes[source.URL.Scheme] {
+			return nil, "", errors.Errorf("can't read datasource %s offline: %s datasources aren't cached", source.Alias, source.URL.Scheme)
+		}
+		return r.Read(ctx, source, args...)
+	}
+
+	ttl := source.cacheTTL
+	if ttl == 0 {
+		ttl = c.ttl
+	}
+
+	log := zerolog.Ctx(ctx)
+	key := c.key(source, args)
+	e, err := c.get(key)
+	if err != nil {
+		return nil, "", err
+	}
+	if e != nil && (c.offline || time.Since(e.Fetched) < ttl) {
+		log.Debug().Str("alias", source.Alias).Time("fetched", e.Fetched).Msg("read datasource from cache")
+		redactCached(source.URL.Scheme, e)
+		return e.Data, e.MediaType, nil
+	}
+	if c.offline {
+		return nil, "", errors.Errorf("can't read datasource %s offline: it isn't cached", source.Alias)
+	}
+
+	data, mediaType, err := r.Read(ctx, source, args...)
+	if err != nil || ttl <= 0 {
+		return data, mediaType, err
+	}
+	err = c.put(key, &cacheEntry{Fetched: time.Now(), MediaType: mediaType, Data: data})
+	if err != nil {
+		// the cache is an optimisation, so the read still succeeds
+		log.Warn().Err(err).Str("alias", source.Alias).Msg("failed to cache datasource")
+	}
+	return data, mediaType, nil
+}
@@ -1,20 +1,21 @@
 package data
 
 import (
+	"context"
 	"strings"
 
 	"github.com/hairyhenderson/gomplate/v3/libkv"
 )
 
-func readConsul(source *Source, args ...string) (data []byte, err error) {
+func readConsul(_ context.Context, source *Source, args ...string) (data []byte, mediaType string, err error) {
 	if source.kv == nil {
 		source.kv, err = libkv.NewConsul(source.URL)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		err = source.kv.Login()
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	}
 
@@ -24,15 +25,15 @@ func readConsul(source *Source, args ...string) (data []byte, err error) {
 	}
 
 	if strings.HasSuffix(p, "/") {
-		source.mediaType = jsonArrayMimetype
+		mediaType = jsonArrayMimetype
 		data, err = source.kv.List(p)
 	} else {
 		data, err = source.kv.Read(p)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return data, nil
+	return data, mediaType, nil
 }
@@ -0,0 +1,51 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// readData - reads the content embedded in a data: URL (RFC 2397), in the form
+// data:[<mediatype>][;base64],<data>. Only overrides can use data: URLs, to
+// give their value inline.
+func readData(_ context.Context, source *Source, args ...string) ([]byte, string, error) {
+	parts := strings.SplitN(source.URL.Opaque, ",", 2)
+	if len(parts) != 2 {
+		return nil, "", errors.Errorf("invalid data URL %s: missing ','", source.URL)
+	}
+	mediatype, content := parts[0], parts[1]
+	mediaType := ""
+
+	isBase64 := false
+	if strings.HasSuffix(mediatype, ";base64") {
+		isBase64 = true
+		mediatype = strings.TrimSuffix(mediatype, ";base64")
+	}
+
+	if mediatype != "" {
+		t, _, err := mime.ParseMediaType(mediatype)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "invalid data URL media type %q", mediatype)
+		}
+		mediaType = t
+	}
+
+	if isBase64 {
+		b, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "can't decode base64 data URL content")
+		}
+		return b, mediaType, nil
+	}
+
+	s, err := url.PathUnescape(content)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "can't unescape data URL content")
+	}
+	return []byte(s), mediaType, nil
+}
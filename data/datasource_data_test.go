@@ -0,0 +1,36 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadData(t *testing.T) {
+	source := &Source{Alias: "foo", URL: mustParseURL("data:,hello%20world")}
+	actual, mediaType, err := readData(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), actual)
+	assert.Equal(t, "", mediaType)
+
+	source = &Source{Alias: "foo", URL: mustParseURL(`data:application/json,{"foo":"bar"}`)}
+	actual, mediaType, err = readData(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), actual)
+	assert.Equal(t, jsonMimetype, mediaType)
+
+	source = &Source{Alias: "foo", URL: mustParseURL("data:application/yaml;base64,Zm9vOiBiYXIK")}
+	actual, mediaType, err = readData(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("foo: bar\n"), actual)
+	assert.Equal(t, yamlMimetype, mediaType)
+
+	source = &Source{Alias: "foo", URL: mustParseURL("data:text/plain")}
+	_, _, err = readData(context.Background(), source)
+	assert.Error(t, err)
+
+	source = &Source{Alias: "foo", URL: mustParseURL("data:;base64,!!!")}
+	_, _, err = readData(context.Background(), source)
+	assert.Error(t, err)
+}
@@ -1,12 +1,13 @@
 package data
 
 import (
+	"context"
 	"strings"
 
 	"github.com/hairyhenderson/gomplate/v3/env"
 )
 
-func readEnv(source *Source, args ...string) (b []byte, err error) {
+func readEnv(_ context.Context, source *Source, args ...string) (b []byte, mediaType string, err error) {
 	n := source.URL.Path
 	n = strings.TrimPrefix(n, "/")
 	if n == "" {
@@ -14,5 +15,5 @@ func readEnv(source *Source, args ...string) (b []byte, err error) {
 	}
 
 	b = []byte(env.Getenv(n))
-	return b, nil
+	return b, "", nil
 }
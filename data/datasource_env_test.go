@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"testing"
@@ -22,31 +23,31 @@ func TestReadEnv(t *testing.T) {
 
 	source := &Source{Alias: "foo", URL: mustParseURL("env:HELLO_WORLD")}
 
-	actual, err := readEnv(source)
+	actual, _, err := readEnv(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "foo", URL: mustParseURL("env:/HELLO_WORLD")}
 
-	actual, err = readEnv(source)
+	actual, _, err = readEnv(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "foo", URL: mustParseURL("env:///HELLO_WORLD")}
 
-	actual, err = readEnv(source)
+	actual, _, err = readEnv(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "foo", URL: mustParseURL("env:HELLO_WORLD?foo=bar")}
 
-	actual, err = readEnv(source)
+	actual, _, err = readEnv(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "foo", URL: mustParseURL("env:///HELLO_WORLD?foo=bar")}
 
-	actual, err = readEnv(source)
+	actual, _, err = readEnv(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 }
@@ -2,6 +2,7 @@ package data
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/url"
@@ -14,7 +15,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-func readFile(source *Source, args ...string) ([]byte, error) {
+func readFile(_ context.Context, source *Source, args ...string) ([]byte, string, error) {
 	if source.fs == nil {
 		source.fs = afero.NewOsFs()
 	}
@@ -24,7 +25,7 @@ func readFile(source *Source, args ...string) ([]byte, error) {
 	if len(args) == 1 {
 		parsed, err := url.Parse(args[0])
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		if parsed.Path != "" {
@@ -35,29 +36,29 @@ func readFile(source *Source, args ...string) ([]byte, error) {
 	// make sure we can access the file
 	i, err := source.fs.Stat(p)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Can't stat %s", p)
+		return nil, "", errors.Wrapf(err, "Can't stat %s", p)
 	}
 
 	if strings.HasSuffix(p, string(filepath.Separator)) {
-		source.mediaType = jsonArrayMimetype
 		if i.IsDir() {
-			return readFileDir(source, p)
+			b, err := readFileDir(source, p)
+			return b, jsonArrayMimetype, err
 		}
-		return nil, errors.Errorf("%s is not a directory", p)
+		return nil, "", errors.Errorf("%s is not a directory", p)
 	}
 
 	f, err := source.fs.OpenFile(p, os.O_RDONLY, 0)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Can't open %s", p)
+		return nil, "", errors.Wrapf(err, "Can't open %s", p)
 	}
 
 	defer f.Close()
 
 	b, err := ioutil.ReadAll(f)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Can't read %s", p)
+		return nil, "", errors.Wrapf(err, "Can't read %s", p)
 	}
-	return b, nil
+	return b, "", nil
 }
 
 func readFileDir(source *Source, p string) ([]byte, error) {
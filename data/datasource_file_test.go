@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -26,30 +27,30 @@ func TestReadFile(t *testing.T) {
 	source := &Source{Alias: "foo", URL: mustParseURL("file:///tmp/foo")}
 	source.fs = fs
 
-	actual, err := readFile(source)
+	actual, _, err := readFile(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "bogus", URL: mustParseURL("file:///bogus")}
 	source.fs = fs
-	_, err = readFile(source)
+	_, _, err = readFile(context.Background(), source)
 	assert.Error(t, err)
 
 	source = &Source{Alias: "partial", URL: mustParseURL("file:///tmp/partial")}
 	source.fs = fs
-	actual, err = readFile(source, "foo.txt")
+	actual, _, err = readFile(context.Background(), source, "foo.txt")
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 
 	source = &Source{Alias: "dir", URL: mustParseURL("file:///tmp/partial/")}
 	source.fs = fs
-	actual, err = readFile(source)
+	actual, _, err = readFile(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(`["bar.txt","baz.txt","foo.txt"]`), actual)
 
 	source = &Source{Alias: "dir", URL: mustParseURL("file:///tmp/partial/?type=application/json")}
 	source.fs = fs
-	actual, err = readFile(source)
+	actual, _, err = readFile(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(`["bar.txt","baz.txt","foo.txt"]`), actual)
 	mime, err := source.mimeType("")
@@ -58,7 +59,7 @@ func TestReadFile(t *testing.T) {
 
 	source = &Source{Alias: "dir", URL: mustParseURL("file:///tmp/partial/?type=application/json")}
 	source.fs = fs
-	actual, err = readFile(source, "foo.txt")
+	actual, _, err = readFile(context.Background(), source, "foo.txt")
 	assert.NoError(t, err)
 	assert.Equal(t, content, actual)
 	mime, err = source.mimeType("")
@@ -27,14 +27,13 @@ import (
 	"github.com/go-git/go-git/v5/storage/memory"
 )
 
-func readGit(source *Source, args ...string) ([]byte, error) {
-	ctx := context.TODO()
+func readGit(ctx context.Context, source *Source, args ...string) ([]byte, string, error) {
 	g := gitsource{}
 
 	u := source.URL
 	repoURL, path, err := g.parseGitPath(u, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	depth := 1
@@ -45,14 +44,11 @@ func readGit(source *Source, args ...string) ([]byte, error) {
 
 	fs, _, err := g.clone(ctx, repoURL, depth)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	mimeType, out, err := g.read(fs, path)
-	if mimeType != "" {
-		source.mediaType = mimeType
-	}
-	return out, err
+	return out, mimeType, err
 }
 
 type gitsource struct {
@@ -389,7 +389,7 @@ func TestReadGit(t *testing.T) {
 		Alias: "hi",
 		URL:   mustParseURL("git+file:///bare.git//hello.txt"),
 	}
-	b, err := readGit(s)
+	b, _, err := readGit(context.Background(), s)
 	assert.NilError(t, err)
 	assert.Equal(t, "hello world", string(b))
 
@@ -397,9 +397,9 @@ func TestReadGit(t *testing.T) {
 		Alias: "hi",
 		URL:   mustParseURL("git+file:///bare.git"),
 	}
-	b, err = readGit(s)
+	b, mediaType, err := readGit(context.Background(), s)
 	assert.NilError(t, err)
-	assert.Equal(t, "application/array+json", s.mediaType)
+	assert.Equal(t, "application/array+json", mediaType)
 	assert.Equal(t, `["hello.txt"]`, string(b))
 }
 
@@ -1,15 +1,68 @@
 package data
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
 	"github.com/pkg/errors"
 )
 
+const (
+	defaultHTTPTimeout   = 5 * time.Second
+	defaultHTTPRetryWait = 250 * time.Millisecond
+)
+
+// httpParams - URL query parameters which set HTTP client options, overriding
+// the datasource's config. They're removed from the URL before it's requested.
+var httpParams = map[string]func(h *config.HTTPConfig, v string) error{
+	"httpTimeout": func(h *config.HTTPConfig, v string) (err error) {
+		h.Timeout, err = time.ParseDuration(v)
+		return err
+	},
+	"httpRetries": func(h *config.HTTPConfig, v string) (err error) {
+		h.Retries, err = strconv.Atoi(v)
+		return err
+	},
+	"httpRetryWait": func(h *config.HTTPConfig, v string) (err error) {
+		h.RetryWait, err = time.ParseDuration(v)
+		return err
+	},
+	"httpCACert": func(h *config.HTTPConfig, v string) error {
+		h.CACert = v
+		return nil
+	},
+	"httpClientCert": func(h *config.HTTPConfig, v string) error {
+		h.ClientCert = v
+		return nil
+	},
+	"httpClientKey": func(h *config.HTTPConfig, v string) error {
+		h.ClientKey = v
+		return nil
+	},
+	"httpInsecureSkipVerify": func(h *config.HTTPConfig, v string) (err error) {
+		h.InsecureSkipVerify, err = strconv.ParseBool(v)
+		return err
+	},
+	"httpProxy": func(h *config.HTTPConfig, v string) error {
+		h.Proxy = v
+		return nil
+	},
+}
+
 func buildURL(base *url.URL, args ...string) (*url.URL, error) {
 	if len(args) == 0 {
 		return base, nil
@@ -21,42 +74,274 @@ func buildURL(base *url.URL, args ...string) (*url.URL, error) {
 	return base.ResolveReference(p), nil
 }
 
-func readHTTP(source *Source, args ...string) ([]byte, error) {
+func readHTTP(ctx context.Context, source *Source, args ...string) ([]byte, string, error) {
+	cfg, base, err := httpConfigFromURL(source.httpConfig, source.URL)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "datasource %s", source.Alias)
+	}
 	if source.hc == nil {
-		source.hc = &http.Client{Timeout: time.Second * 5}
+		source.hc, err = newHTTPClient(cfg)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "datasource %s", source.Alias)
+		}
+	}
+	// args are given to the body template instead of being used as a path
+	u := base
+	if !cfg.TemplateBody {
+		u, err = buildURL(base, args...)
+		if err != nil {
+			return nil, "", err
+		}
 	}
-	u, err := buildURL(source.URL, args...)
+	method := http.MethodGet
+	if cfg.Method != "" {
+		method = strings.ToUpper(cfg.Method)
+	}
+	reqBody, err := requestBody(cfg, args)
 	if err != nil {
-		return nil, err
+		return nil, "", errors.Wrapf(err, "datasource %s", source.Alias)
 	}
-	req, err := http.NewRequest("GET", u.String(), nil)
+	res, body, err := fetchHTTP(ctx, source, cfg, method, u, source.header, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	if cfg.Paginate.Mode != "" {
+		return readPages(ctx, source, cfg, method, reqBody, u, res, body)
+	}
+	mediaType := ""
+	ctypeHdr := res.Header.Get("Content-Type")
+	if ctypeHdr != "" {
+		mediatype, _, e := mime.ParseMediaType(ctypeHdr)
+		if e != nil {
+			return nil, "", e
+		}
+		mediaType = mediatype
+	}
+	return body, mediaType, nil
+}
+
+// fetchHTTP - send the request with the given headers, returning the response
+// and its body, which must have a 200 status
+func fetchHTTP(ctx context.Context, source *Source, cfg config.HTTPConfig, method string, u *url.URL, header http.Header, reqBody []byte) (*http.Response, []byte, error) {
+	var r io.Reader
+	if reqBody != nil {
+		r = bytes.NewReader(reqBody)
 	}
-	req.Header = source.header
-	res, err := source.hc.Do(req)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if header != nil {
+		req.Header = header.Clone()
+	}
+	if reqBody != nil && req.Header.Get("Content-Type") == "" {
+		// bodies are usually JSON, as for GraphQL or search APIs
+		req.Header.Set("Content-Type", jsonMimetype)
+	}
+	res, err := doHTTP(ctx, source.hc, req, cfg)
+	if err != nil {
+		return nil, nil, err
 	}
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = res.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if res.StatusCode != 200 {
-		err := errors.Errorf("Unexpected HTTP status %d on GET from %s: %s", res.StatusCode, source.URL, string(body))
+		err := errors.Errorf("Unexpected HTTP status %d on %s from %s: %s", res.StatusCode, method, u, string(body))
+		return nil, nil, err
+	}
+	return res, body, nil
+}
+
+// requestBody - the request body, or nil if there isn't one. Templated bodies
+// are rendered with the datasource's args as .Args.
+func requestBody(cfg config.HTTPConfig, args []string) ([]byte, error) {
+	b := []byte(cfg.Body)
+	if cfg.BodyFile != "" {
+		var err error
+		b, err = ioutil.ReadFile(cfg.BodyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read request body")
+		}
+	}
+	if len(b) == 0 {
+		return nil, nil
+	}
+	if !cfg.TemplateBody {
+		return b, nil
+	}
+
+	tmpl, err := template.New("body").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"json": toJSONString}).
+		Parse(string(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse request body template")
+	}
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, map[string]interface{}{"Args": args})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render request body")
+	}
+	return buf.Bytes(), nil
+}
+
+// toJSONString - encode the value as JSON, for embedding args in JSON bodies
+func toJSONString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// httpConfigFromURL - override the HTTP options with any set in the URL's
+// query, returning the URL without those parameters
+func httpConfigFromURL(cfg config.HTTPConfig, u *url.URL) (config.HTTPConfig, *url.URL, error) {
+	q := u.Query()
+	o := config.HTTPConfig{}
+	found := false
+	for name, set := range httpParams {
+		if _, ok := q[name]; !ok {
+			continue
+		}
+		found = true
+		err := set(&o, q.Get(name))
+		if err != nil {
+			return cfg, nil, errors.Wrapf(err, "invalid %s parameter", name)
+		}
+		q.Del(name)
+	}
+	if !found {
+		return cfg, u, nil
+	}
+	cfg = cfg.MergeFrom(o)
+	err := cfg.Validate()
+	if err != nil {
+		return cfg, nil, err
+	}
+	out := cloneURL(u)
+	out.RawQuery = q.Encode()
+	return cfg, out, nil
+}
+
+// NewHTTPClient - a client with the given options, set up the same way as for
+// http and https datasources. Unset options take their defaults, so the client
+// always has a timeout.
+func NewHTTPClient(cfg config.HTTPConfig) (*http.Client, error) {
+	return newHTTPClient(cfg)
+}
+
+// newHTTPClient - a client with the given options. Proxies are taken from the
+// environment unless one is set.
+func newHTTPClient(cfg config.HTTPConfig) (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Proxy != "" {
+		p, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy URL")
+		}
+		t.Proxy = http.ProxyURL(p)
+	}
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
 		return nil, err
 	}
-	ctypeHdr := res.Header.Get("Content-Type")
-	if ctypeHdr != "" {
-		mediatype, _, e := mime.ParseMediaType(ctypeHdr)
-		if e != nil {
-			return nil, e
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
+
+// newTLSConfig - the TLS config for the options, or nil if the defaults are
+// fine
+func newTLSConfig(cfg config.HTTPConfig) (*tls.Config, error) {
+	if cfg.CACert == "" && cfg.ClientCert == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	//nolint:gosec
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CACert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		b, err := ioutil.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA certificates")
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("no certificates found in %s", cfg.CACert)
 		}
-		source.mediaType = mediatype
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// doHTTP - send the request, retrying connection errors and 5xx responses as
+// many times as configured. The wait between attempts doubles each time.
+// POST and PATCH requests aren't idempotent, so 5xx responses to them aren't
+// retried, since the server may have acted on the request.
+func doHTTP(ctx context.Context, hc *http.Client, req *http.Request, cfg config.HTTPConfig) (*http.Response, error) {
+	wait := cfg.RetryWait
+	if wait == 0 {
+		wait = defaultHTTPRetryWait
 	}
-	return body, nil
+	// POST and PATCH requests aren't idempotent, so they're only retried when
+	// they can't have reached the server
+	idempotent := req.Method != http.MethodPost && req.Method != http.MethodPatch
+	for attempt := 0; ; attempt++ {
+		res, err := hc.Do(req)
+		retry := attempt < cfg.Retries && ctx.Err() == nil
+		if err != nil {
+			retry = retry && (idempotent || isDialError(err))
+		} else {
+			retry = retry && idempotent && res.StatusCode >= 500
+		}
+		if !retry {
+			return res, err
+		}
+		if res != nil {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait << attempt):
+		}
+
+		// requests with bodies need a fresh copy of the body
+		if req.GetBody != nil {
+			b, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = b
+		}
+	}
+}
+
+// isDialError - whether the request failed because the connection couldn't be
+// made, in which case it was never sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
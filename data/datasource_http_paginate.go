@@ -0,0 +1,216 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const defaultMaxPages = 100
+
+// readPages - read the rest of the pages following the first response, and
+// concatenate their items into one JSON array
+func readPages(ctx context.Context, source *Source, cfg config.HTTPConfig, method string, reqBody []byte, u *url.URL, res *http.Response, body []byte) ([]byte, string, error) {
+	p := cfg.Paginate
+	maxPages := p.MaxPages
+	if maxPages == 0 {
+		maxPages = defaultMaxPages
+	}
+
+	first := u
+	items := []interface{}{}
+	for page := 1; ; page++ {
+		pageItems, next, err := parsePage(p, u, res.Header, body)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "datasource %s: page %d from %s", source.Alias, page, u)
+		}
+		items = append(items, pageItems...)
+		if next == nil {
+			break
+		}
+		if page >= maxPages {
+			zerolog.Ctx(ctx).Warn().Str("alias", source.Alias).Int("maxPages", maxPages).
+				Msg("stopped reading datasource at the page limit, there are more pages")
+			break
+		}
+
+		// as with redirects, headers (which may hold credentials) aren't sent
+		// to other hosts. Request bodies may hold credentials too, but the
+		// request doesn't make sense without its body, so it's an error.
+		header := source.header
+		if next.Scheme != first.Scheme || next.Host != first.Host {
+			if reqBody != nil {
+				return nil, "", errors.Errorf("datasource %s: page %d links to %s, on a different host, which the request body won't be sent to",
+					source.Alias, page+1, next)
+			}
+			zerolog.Ctx(ctx).Debug().Str("alias", source.Alias).Stringer("url", next).
+				Msg("not sending datasource headers to a different host")
+			header = nil
+		}
+
+		u = next
+		res, body, err = fetchHTTP(ctx, source, cfg, method, u, header, reqBody)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	b, err := json.Marshal(items)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, jsonArrayMimetype, nil
+}
+
+// parsePage - the page's items, and the URL of the next page, or nil if it's
+// the last
+func parsePage(p config.PaginateConfig, u *url.URL, h http.Header, body []byte) ([]interface{}, *url.URL, error) {
+	// numbers are kept as-is, so large IDs aren't mangled by float64s
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc interface{}
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to parse page as JSON")
+	}
+
+	v := doc
+	if p.Items != "" {
+		v, err = findJSONPath(p.Items, doc)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "items")
+		}
+	}
+	items, ok := v.([]interface{})
+	if !ok && v != nil {
+		return nil, nil, errors.Errorf("expected a JSON array, got %T", v)
+	}
+
+	var next *url.URL
+	switch p.Mode {
+	case config.PaginateLink:
+		next, err = linkNext(u, h)
+	case config.PaginateCursor:
+		next, err = cursorNext(u, p, doc)
+	}
+	return items, next, err
+}
+
+// linkNext - the URL of the next page from the response's `Link` headers (see
+// RFC 8288), resolved against the current URL, or nil if there isn't one
+func linkNext(u *url.URL, h http.Header) (*url.URL, error) {
+	for _, v := range h.Values("Link") {
+		for _, link := range strings.Split(v, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			if !hasRel(parts[1:], "next") {
+				continue
+			}
+			next, err := url.Parse(target[1 : len(target)-1])
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid next link")
+			}
+			return u.ResolveReference(next), nil
+		}
+	}
+	return nil, nil
+}
+
+// hasRel - whether the link params have the given relation type. The rel
+// param may hold several space-separated types.
+func hasRel(params []string, rel string) bool {
+	for _, param := range params {
+		i := strings.Index(param, "=")
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(param[:i]), "rel") {
+			continue
+		}
+		for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(param[i+1:]), `"`)) {
+			if strings.EqualFold(r, rel) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cursorNext - the URL of the next page, from the cursor in the page. The
+// cursor is set as a query parameter, or is the URL itself when there's no
+// parameter. There are no more pages when the cursor is missing or empty.
+func cursorNext(u *url.URL, p config.PaginateConfig, doc interface{}) (*url.URL, error) {
+	v, err := findJSONPath(p.NextCursor, doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "nextCursor")
+	}
+	var cursor string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		cursor = v
+	case json.Number:
+		cursor = v.String()
+	case bool:
+		cursor = strconv.FormatBool(v)
+	default:
+		return nil, errors.Errorf("nextCursor: expected a string or number, got %T", v)
+	}
+	if cursor == "" {
+		return nil, nil
+	}
+
+	if p.CursorParam == "" {
+		next, err := url.Parse(cursor)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid next page URL")
+		}
+		return u.ResolveReference(next), nil
+	}
+	next := cloneURL(u)
+	q := next.Query()
+	q.Set(p.CursorParam, cursor)
+	next.RawQuery = q.Encode()
+	return next, nil
+}
+
+// findJSONPath - the value at the JSONPath in the document, or nil if there's
+// nothing there. Paths matching more than one value are an error.
+func findJSONPath(path string, doc interface{}) (interface{}, error) {
+	j := jsonpath.New("page")
+	j.AllowMissingKeys(true)
+	err := j.Parse(fmt.Sprintf("{%s}", path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "couldn't parse JSONPath %s", path)
+	}
+	results, err := j.FindResults(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "executing JSONPath %s failed", path)
+	}
+	var found []interface{}
+	for _, r := range results {
+		for _, v := range r {
+			if v.IsValid() && v.CanInterface() {
+				found = append(found, v.Interface())
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	default:
+		return nil, errors.Errorf("JSONPath %s matched %d values, expected 1", path, len(found))
+	}
+}
@@ -0,0 +1,205 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkNext(t *testing.T) {
+	u := mustParseURL("https://api.example.com/repos?page=1")
+	testdata := []struct {
+		links    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{`<https://api.example.com/repos?page=5>; rel="last"`}, ""},
+		{
+			[]string{`<https://api.example.com/repos?page=2>; rel="next", <https://api.example.com/repos?page=5>; rel="last"`},
+			"https://api.example.com/repos?page=2",
+		},
+		{
+			[]string{`</repos?page=1>; rel="prev first"`, `</repos?page=3>; rel="next last"`},
+			"https://api.example.com/repos?page=3",
+		},
+		{[]string{`<?page=2>;rel=next`}, "https://api.example.com/repos?page=2"},
+		{[]string{`https://api.example.com/repos?page=2; rel="next"`}, ""},
+	}
+	for _, d := range testdata {
+		h := http.Header{}
+		for _, l := range d.links {
+			h.Add("Link", l)
+		}
+		next, err := linkNext(u, h)
+		assert.NoError(t, err)
+		if d.expected == "" {
+			assert.Nil(t, next, d.links)
+		} else {
+			assert.Equal(t, d.expected, next.String(), d.links)
+		}
+	}
+}
+
+func TestFindJSONPath(t *testing.T) {
+	doc := map[string]interface{}{
+		"data": []interface{}{"a", "b"},
+		"meta": map[string]interface{}{"next": nil},
+	}
+	v, err := findJSONPath("$.data", doc)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, v)
+
+	v, err = findJSONPath(".meta.next", doc)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	v, err = findJSONPath(".meta.missing", doc)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = findJSONPath(".data[*]", doc)
+	assert.Error(t, err)
+
+	_, err = findJSONPath(".data[", doc)
+	assert.Error(t, err)
+}
+
+func TestHTTPPaginateLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		w.Header().Set("Content-Type", jsonMimetype)
+		fmt.Fprintf(w, `[{"id": %d1}, {"id": %d2}]`, page, page)
+	}))
+	defer srv.Close()
+
+	s := &Source{Alias: "items", URL: mustParseURL(srv.URL + "/items"), httpConfig: config.HTTPConfig{
+		Paginate: config.PaginateConfig{Mode: config.PaginateLink},
+	}}
+	b, mediaType, err := readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonArrayMimetype, mediaType)
+	assert.JSONEq(t, `[{"id": 11}, {"id": 12}, {"id": 21}, {"id": 22}, {"id": 31}, {"id": 32}]`, string(b))
+
+	// stop at the page limit
+	s.httpConfig.Paginate.MaxPages = 2
+	b, _, err = readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"id": 11}, {"id": 12}, {"id": 21}, {"id": 22}]`, string(b))
+
+	// missing items are empty, but items must be arrays
+	s = &Source{Alias: "obj", URL: mustParseURL(srv.URL + "/items"), httpConfig: config.HTTPConfig{
+		Paginate: config.PaginateConfig{Mode: config.PaginateLink, Items: ".missing"},
+	}}
+	b, _, err = readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(b))
+
+	s.httpConfig.Paginate.Items = "[0]"
+	_, _, err = readHTTP(context.Background(), s)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "page 1")
+	assert.Contains(t, err.Error(), "expected a JSON array")
+}
+
+func TestHTTPPaginateCrossHost(t *testing.T) {
+	var otherAuth []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherAuth = append(otherAuth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", jsonMimetype)
+		fmt.Fprint(w, `[3]`)
+	}))
+	defer other.Close()
+
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", jsonMimetype)
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+			fmt.Fprint(w, `[1]`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=3>; rel="next"`, other.URL))
+		fmt.Fprint(w, `[2]`)
+	}))
+	defer srv.Close()
+
+	s := &Source{
+		Alias:  "items",
+		URL:    mustParseURL(srv.URL + "/items"),
+		header: http.Header{"Authorization": {"Bearer secret"}},
+		httpConfig: config.HTTPConfig{
+			Paginate: config.PaginateConfig{Mode: config.PaginateLink},
+		},
+	}
+	b, _, err := readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, `[1,2,3]`, string(b))
+
+	// the credentials are only sent to the datasource's host
+	assert.Equal(t, []string{"Bearer secret", "Bearer secret"}, auth)
+	assert.Equal(t, []string{""}, otherAuth)
+
+	// requests with bodies aren't sent to other hosts at all
+	auth, otherAuth = nil, nil
+	s.httpConfig.Method = "POST"
+	s.httpConfig.Body = `{"token": "secret"}`
+	_, _, err = readHTTP(context.Background(), s)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "page 3 links to "+other.URL+"/items?page=3, on a different host")
+	assert.Len(t, auth, 2)
+	assert.Empty(t, otherAuth)
+}
+
+func TestHTTPPaginateCursor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", jsonMimetype)
+		switch r.URL.Query().Get("after") {
+		case "":
+			fmt.Fprint(w, `{"data": [1, 2], "meta": {"next": "abc", "url": "/list?after=abc"}}`)
+		case "abc":
+			fmt.Fprint(w, `{"data": [3, 9007199254740993], "meta": {"next": null}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s := &Source{Alias: "list", URL: mustParseURL(srv.URL + "/list"), httpConfig: config.HTTPConfig{
+		Paginate: config.PaginateConfig{
+			Mode:        config.PaginateCursor,
+			NextCursor:  ".meta.next",
+			CursorParam: "after",
+			Items:       "$.data",
+		},
+	}}
+	d := &Data{Sources: map[string]*Source{"list": s}}
+	out, err := d.Datasource("list")
+	assert.NoError(t, err)
+	assert.Len(t, out, 4)
+
+	// large numbers aren't rounded
+	b, mediaType, err := readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonArrayMimetype, mediaType)
+	assert.Equal(t, `[1,2,3,9007199254740993]`, string(b))
+
+	// without a param, the cursor is the next page's URL
+	s.httpConfig.Paginate.NextCursor = ".meta.url"
+	s.httpConfig.Paginate.CursorParam = ""
+	b, _, err = readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, `[1,2,3,9007199254740993]`, string(b))
+}
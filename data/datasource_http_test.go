@@ -1,13 +1,29 @@
 package data
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -143,3 +159,251 @@ func TestBuildURL(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, u.String())
 }
+
+func TestHTTPConfigFromURL(t *testing.T) {
+	defaults := config.HTTPConfig{Timeout: time.Second, Retries: 1}
+
+	u := mustParseURL("https://example.com/foo?a=b")
+	cfg, out, err := httpConfigFromURL(defaults, u)
+	assert.NoError(t, err)
+	assert.Equal(t, defaults, cfg)
+	assert.Same(t, u, out)
+
+	u = mustParseURL("https://example.com/foo?a=b&httpRetries=3&httpRetryWait=1s&httpInsecureSkipVerify=true&httpProxy=http://proxy:3128")
+	cfg, out, err = httpConfigFromURL(defaults, u)
+	assert.NoError(t, err)
+	assert.Equal(t, config.HTTPConfig{
+		Timeout:            time.Second,
+		Retries:            3,
+		RetryWait:          time.Second,
+		InsecureSkipVerify: true,
+		Proxy:              "http://proxy:3128",
+	}, cfg)
+	assert.Equal(t, "https://example.com/foo?a=b", out.String())
+
+	_, _, err = httpConfigFromURL(defaults, mustParseURL("https://example.com?httpTimeout=soon"))
+	assert.Error(t, err)
+
+	_, _, err = httpConfigFromURL(defaults, mustParseURL("https://example.com?httpClientCert=cert.pem"))
+	assert.Error(t, err)
+}
+
+func TestHTTPRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", jsonMimetype)
+		fmt.Fprintf(w, `{"calls": %d}`, calls)
+	}))
+	defer srv.Close()
+
+	s := &Source{Alias: "foo", URL: mustParseURL(srv.URL + "/?httpRetries=2&httpRetryWait=1ms")}
+	b, mt, err := readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"calls": 3}`, string(b))
+	assert.Equal(t, jsonMimetype, mt)
+
+	// the last 5xx response is returned once the retries run out
+	calls = 0
+	s = &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: config.HTTPConfig{Retries: 1, RetryWait: time.Millisecond}}
+	_, _, err = readHTTP(context.Background(), s)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unexpected HTTP status 502")
+	assert.Equal(t, 2, calls)
+
+	// connection errors are retried
+	srv.Close()
+	start := time.Now()
+	s = &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: config.HTTPConfig{Retries: 2, RetryWait: 20 * time.Millisecond}}
+	_, _, err = readHTTP(context.Background(), s)
+	assert.Error(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(60*time.Millisecond))
+}
+
+func TestHTTPTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, clientKey := writeTestCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caCert := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	assert.NoError(t, err)
+
+	// the server's certificate isn't trusted by default
+	s := &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: config.HTTPConfig{
+		ClientCert: clientCert, ClientKey: clientKey,
+	}}
+	_, _, err = readHTTP(context.Background(), s)
+	assert.Error(t, err)
+
+	s = &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: config.HTTPConfig{
+		CACert: caCert, ClientCert: clientCert, ClientKey: clientKey,
+	}}
+	b, _, err := readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, "gomplate-test", string(b))
+
+	s = &Source{Alias: "foo", URL: mustParseURL(srv.URL + "?httpInsecureSkipVerify=true&httpClientCert=" + clientCert + "&httpClientKey=" + clientKey)}
+	b, _, err = readHTTP(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, "gomplate-test", string(b))
+
+	_, err = newHTTPClient(config.HTTPConfig{CACert: clientKey})
+	assert.Error(t, err)
+}
+
+// writeTestCert - write a self-signed client certificate and its key to the
+// directory, returning their paths
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gomplate-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestRequestBody(t *testing.T) {
+	b, err := requestBody(config.HTTPConfig{}, []string{"foo"})
+	assert.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = requestBody(config.HTTPConfig{Body: `{"a": "{{ .Args }}"}`}, []string{"foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a": "{{ .Args }}"}`, string(b))
+
+	cfg := config.HTTPConfig{Body: `{"query": {{ index .Args 0 | json }}}`, TemplateBody: true}
+	b, err = requestBody(cfg, []string{`query { a(name: "b") }`})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"query": "query { a(name: \"b\") }"}`, string(b))
+
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	assert.NoError(t, os.WriteFile(bodyFile, []byte(`{{ len .Args }}`), 0600))
+	b, err = requestBody(config.HTTPConfig{BodyFile: bodyFile, TemplateBody: true}, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+
+	_, err = requestBody(config.HTTPConfig{Body: "{{ .Bogus }}", TemplateBody: true}, nil)
+	assert.Error(t, err)
+
+	_, err = requestBody(config.HTTPConfig{BodyFile: "/no/such/file"}, nil)
+	assert.Error(t, err)
+}
+
+func TestHTTPPost(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/flaky" && calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", jsonMimetype)
+		fmt.Fprintf(w, `{"method": %q, "path": %q, "body": %q, "type": %q}`,
+			r.Method, r.URL.Path, b, r.Header.Get("Content-Type"))
+	}))
+	defer srv.Close()
+
+	s := &Source{Alias: "gql", URL: mustParseURL(srv.URL + "/graphql"), httpConfig: config.HTTPConfig{
+		Method:       "post",
+		Body:         `{"query": {{ index .Args 0 | json }}}`,
+		TemplateBody: true,
+	}}
+	d := &Data{Sources: map[string]*Source{"gql": s}}
+	out, err := d.Datasource("gql", "query { services { name } }")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"method": "POST",
+		"path":   "/graphql",
+		"body":   `{"query": "query { services { name } }"}`,
+		"type":   jsonMimetype,
+	}, out)
+
+	// without a template, args are still sub-paths, and the body is re-sent
+	// on retries
+	calls = 0
+	s = &Source{Alias: "search", URL: mustParseURL(srv.URL + "/"), header: http.Header{"Content-Type": {"text/plain"}}, httpConfig: config.HTTPConfig{
+		Method:    "PUT",
+		Body:      "hello",
+		Retries:   1,
+		RetryWait: time.Millisecond,
+	}}
+	b, _, err := readHTTP(context.Background(), s, "flaky")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"method": "PUT", "path": "/flaky", "body": "hello", "type": "text/plain"}`, string(b))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, http.Header{"Content-Type": {"text/plain"}}, s.header)
+
+	// 5xx responses to POSTs aren't retried
+	calls = 0
+	s.httpConfig.Method = "POST"
+	_, _, err = readHTTP(context.Background(), s, "flaky")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Unexpected HTTP status 503")
+	assert.Equal(t, 1, calls)
+}
+
+func TestHTTPRetryTransportErrors(t *testing.T) {
+	// the connection is dropped after the request is received
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = ioutil.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.HTTPConfig{Body: "hello", Retries: 2, RetryWait: time.Millisecond}
+	for method, expected := range map[string]int{"PUT": 3, "POST": 1, "PATCH": 1} {
+		calls = 0
+		cfg.Method = method
+		s := &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: cfg}
+		_, _, err := readHTTP(context.Background(), s)
+		assert.Error(t, err)
+		assert.Equal(t, expected, calls, method)
+	}
+
+	// POSTs are retried when the connection can't be made, since they can't
+	// have been sent
+	srv.Close()
+	cfg.Method = "POST"
+	cfg.RetryWait = 20 * time.Millisecond
+	start := time.Now()
+	s := &Source{Alias: "foo", URL: mustParseURL(srv.URL), httpConfig: cfg}
+	_, _, err := readHTTP(context.Background(), s)
+	assert.Error(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(60*time.Millisecond))
+
+	assert.False(t, isDialError(errors.New("EOF")))
+	assert.True(t, isDialError(&url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}))
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"strings"
 
 	"github.com/hairyhenderson/gomplate/v3/coll"
@@ -19,11 +20,11 @@ import (
 // the source data. To merge datasources with query strings or fragments, define
 // separate sources first and specify the alias names. HTTP headers are also not
 // supported directly.
-func (d *Data) readMerge(source *Source, args ...string) ([]byte, error) {
+func (d *Data) readMerge(_ context.Context, source *Source, args ...string) ([]byte, string, error) {
 	opaque := source.URL.Opaque
 	parts := strings.Split(opaque, "|")
 	if len(parts) < 2 {
-		return nil, errors.New("need at least 2 datasources to merge")
+		return nil, "", errors.New("need at least 2 datasources to merge")
 	}
 	data := make([]map[string]interface{}, len(parts))
 	for i, part := range parts {
@@ -33,39 +34,35 @@ func (d *Data) readMerge(source *Source, args ...string) ([]byte, error) {
 			// maybe it's a relative filename?
 			u, uerr := config.ParseSourceURL(part)
 			if uerr != nil {
-				return nil, uerr
-			}
-			subSource = &Source{
-				Alias: part,
-				URL:   u,
+				return nil, "", uerr
 			}
+			subSource = d.newSource(part, config.DataSource{URL: u})
 		}
 		subSource.inherit(source)
 
 		b, err := d.readSource(subSource)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Couldn't read datasource '%s'", part)
+			return nil, "", errors.Wrapf(err, "Couldn't read datasource '%s'", part)
 		}
 
 		mimeType, err := subSource.mimeType("")
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to read datasource %s", subSource.URL)
+			return nil, "", errors.Wrapf(err, "failed to read datasource %s", subSource.URL)
 		}
 
 		data[i], err = parseMap(mimeType, string(b))
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	}
 
 	// Merge the data together
 	b, err := mergeData(data)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	source.mediaType = yamlMimetype
-	return b, nil
+	return b, yamlMimetype, nil
 }
 
 func mergeData(data []map[string]interface{}) (out []byte, err error) {
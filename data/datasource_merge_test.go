@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -55,38 +56,38 @@ func TestReadMerge(t *testing.T) {
 		},
 	}
 
-	actual, err := d.readMerge(source)
+	actual, _, err := d.readMerge(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, mergedContent, string(actual))
 
 	source.URL = mustParseURL("merge:bar|baz")
-	actual, err = d.readMerge(source)
+	actual, _, err = d.readMerge(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, mergedContent, string(actual))
 
 	source.URL = mustParseURL("merge:./jsonfile.json|baz")
-	actual, err = d.readMerge(source)
+	actual, _, err = d.readMerge(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, mergedContent, string(actual))
 
 	source.URL = mustParseURL("merge:file:///tmp/jsonfile.json")
-	_, err = d.readMerge(source)
+	_, _, err = d.readMerge(context.Background(), source)
 	assert.Error(t, err)
 
 	source.URL = mustParseURL("merge:bogusalias|file:///tmp/jsonfile.json")
-	_, err = d.readMerge(source)
+	_, _, err = d.readMerge(context.Background(), source)
 	assert.Error(t, err)
 
 	source.URL = mustParseURL("merge:file:///tmp/jsonfile.json|badscheme")
-	_, err = d.readMerge(source)
+	_, _, err = d.readMerge(context.Background(), source)
 	assert.Error(t, err)
 
 	source.URL = mustParseURL("merge:file:///tmp/jsonfile.json|badtype")
-	_, err = d.readMerge(source)
+	_, _, err = d.readMerge(context.Background(), source)
 	assert.Error(t, err)
 
 	source.URL = mustParseURL("merge:file:///tmp/jsonfile.json|array")
-	_, err = d.readMerge(source)
+	_, _, err = d.readMerge(context.Background(), source)
 	assert.Error(t, err)
 }
 
@@ -0,0 +1,51 @@
+package data_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hairyhenderson/gomplate/v3/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterReaderExternal(t *testing.T) {
+	var gotHeader http.Header
+	var gotArgs []string
+	data.RegisterReader("test+ext", data.ReaderFunc(func(ctx context.Context, source *data.Source, args ...string) ([]byte, string, error) {
+		gotHeader = source.Header()
+		gotArgs = args
+		return []byte("host: " + source.URL.Host + "\nalias: " + source.Alias + "\n"), source.MediaType(), nil
+	}))
+
+	d, err := data.NewData(
+		[]string{"ext=test+ext://example.com/config.yaml"},
+		[]string{"ext=X-Token: abcd1234"},
+	)
+	assert.NoError(t, err)
+
+	actual, err := d.Datasource("ext", "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"host": "example.com", "alias": "ext"}, actual)
+	assert.Equal(t, "abcd1234", gotHeader.Get("X-Token"))
+	assert.Equal(t, []string{"foo"}, gotArgs)
+
+	// the source's header can't be changed through the copy
+	gotHeader.Set("X-Token", "changed")
+	_, err = d.Datasource("ext", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "abcd1234", gotHeader.Get("X-Token"))
+}
+
+func TestSourceMediaType(t *testing.T) {
+	d, err := data.NewData([]string{
+		"a=test+ext://example.com/a.json",
+		"b=test+ext://example.com/b?type=application/yaml",
+		"c=test+ext://example.com/c",
+	}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "application/json", d.Sources["a"].MediaType())
+	assert.Equal(t, "application/yaml", d.Sources["b"].MediaType())
+	assert.Equal(t, "text/plain", d.Sources["c"].MediaType())
+}
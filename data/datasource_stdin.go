@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 
@@ -10,10 +11,10 @@ import (
 // stdin - for overriding in tests
 var stdin io.Reader
 
-func readStdin(source *Source, args ...string) ([]byte, error) {
+func readStdin(_ context.Context, source *Source, args ...string) ([]byte, string, error) {
 	b, err := ioutil.ReadAll(stdin)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Can't read %s", stdin)
+		return nil, "", errors.Wrapf(err, "Can't read %s", stdin)
 	}
-	return b, nil
+	return b, "", nil
 }
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -12,11 +13,11 @@ func TestReadStdin(t *testing.T) {
 		stdin = nil
 	}()
 	stdin = strings.NewReader("foo")
-	out, err := readStdin(nil)
+	out, _, err := readStdin(context.Background(), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte("foo"), out)
 
 	stdin = errorReader{}
-	_, err = readStdin(nil)
+	_, _, err = readStdin(context.Background(), nil)
 	assert.Error(t, err)
 }
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
 	"github.com/spf13/afero"
@@ -411,4 +412,182 @@ func TestFromConfig(t *testing.T) {
 		},
 	}
 	assert.EqualValues(t, expected, FromConfig(ctx, cfg))
+
+	cfg = &config.Config{
+		DataSources: map[string]config.DataSource{
+			"foo": {
+				URL: mustParseURL("vault:///secret/foo"),
+			},
+		},
+		DataSourceOverrides: map[string]config.DataSource{
+			"foo": {
+				URL: mustParseURL("file:///tmp/foo.json"),
+			},
+		},
+	}
+	expected = &Data{
+		ctx: ctx,
+		Sources: map[string]*Source{
+			"foo": {
+				Alias:   "foo",
+				URL:     mustParseURL("file:///tmp/foo.json"),
+				origURL: mustParseURL("vault:///secret/foo"),
+			},
+		},
+		overrides: cfg.DataSourceOverrides,
+	}
+	assert.EqualValues(t, expected, FromConfig(ctx, cfg))
+}
+
+func TestDatasourceOverrides(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		DataSources: map[string]config.DataSource{
+			"foo": {URL: mustParseURL("vault:///secret/foo?type=application/yaml")},
+		},
+		DataSourceOverrides: map[string]config.DataSource{
+			"foo": {URL: mustParseURL("data:,bar:%20baz")},
+			"qux": {URL: mustParseURL(`data:,{"hello":"world"}`)},
+		},
+	}
+	d := FromConfig(ctx, cfg)
+
+	actual, err := d.Datasource("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"bar": "baz"}, actual)
+
+	err = d.VerifyOverrides()
+	assert.EqualError(t, err, "datasource override(s) given for unused alias(es): qux")
+
+	_, err = d.DefineDatasource("qux", "https://example.com/qux.json")
+	assert.NoError(t, err)
+	actual, err = d.Datasource("qux")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"hello": "world"}, actual)
+
+	assert.NoError(t, d.VerifyOverrides())
+
+	// data: URLs can only be used in overrides
+	_, err = d.DefineDatasource("inline", "data:,hello")
+	assert.NoError(t, err)
+	_, err = d.Datasource("inline")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "scheme data not registered")
+}
+
+func TestAddSources(t *testing.T) {
+	t.Setenv("TEST_FOO", `{"a":1}`)
+	t.Setenv("TEST_BAR", `{"b":1}`)
+	t.Setenv("TEST_BAR2", `{"b":2}`)
+	ctx := context.Background()
+	cfg := &config.Config{
+		DataSources: map[string]config.DataSource{
+			"foo": {URL: mustParseURL("env:TEST_FOO?type=application/json")},
+			"bar": {URL: mustParseURL("env:TEST_BAR?type=application/json")},
+		},
+		DataSourceOverrides: map[string]config.DataSource{
+			"baz": {URL: mustParseURL(`data:,{"c":2}`)},
+		},
+	}
+	d := FromConfig(ctx, cfg)
+	_, err := d.Datasource("foo")
+	assert.NoError(t, err)
+	foo := d.Sources["foo"]
+
+	d.AddSources(map[string]config.DataSource{
+		"foo": {URL: mustParseURL("env:TEST_FOO?type=application/json")},
+		"bar": {URL: mustParseURL("env:TEST_BAR2?type=application/json")},
+		"baz": {URL: mustParseURL("https://example.com/baz.json")},
+	})
+
+	// unchanged sources are kept
+	assert.Same(t, foo, d.Sources["foo"])
+
+	actual, err := d.Datasource("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"b": 2}, actual)
+
+	actual, err = d.Datasource("baz")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"c": 2}, actual)
+	assert.NoError(t, d.VerifyOverrides())
+}
+
+func TestSetOverrides(t *testing.T) {
+	t.Setenv("TEST_FOO", `{"a":1}`)
+	ctx := context.Background()
+	sources := map[string]config.DataSource{
+		"foo": {URL: mustParseURL("env:TEST_FOO?type=application/json")},
+		"bar": {URL: mustParseURL("env:TEST_FOO?type=application/json")},
+	}
+	d := FromConfig(ctx, &config.Config{DataSources: sources})
+	_, err := d.Datasource("bar")
+	assert.NoError(t, err)
+	bar := d.Sources["bar"]
+
+	d.SetOverrides(map[string]config.DataSource{
+		"foo": {URL: mustParseURL(`data:,{"a":2}`)},
+		"baz": {URL: mustParseURL(`data:,{"c":3}`)},
+	})
+	d.AddSources(sources)
+	actual, err := d.Datasource("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 2}, actual)
+
+	// sources without overrides are kept
+	assert.Same(t, bar, d.Sources["bar"])
+
+	// the override no longer applies
+	d.SetOverrides(nil)
+	d.AddSources(sources)
+	actual, err = d.Datasource("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": 1}, actual)
+
+	// replaced overrides are still verified
+	err = d.VerifyOverrides()
+	assert.EqualError(t, err, "datasource override(s) given for unused alias(es): baz")
+}
+
+func TestRegisterReader(t *testing.T) {
+	var gotCtx context.Context
+	var gotArgs []string
+	RegisterReader("test+kv", ReaderFunc(func(ctx context.Context, source *Source, args ...string) ([]byte, string, error) {
+		gotCtx = ctx
+		gotArgs = args
+		return []byte(`{"host":"` + source.URL.Host + `"}`), jsonMimetype, nil
+	}))
+	assert.True(t, SupportedScheme("test+kv"))
+	assert.True(t, SupportedScheme("merge"))
+	assert.False(t, SupportedScheme("test+bogus"))
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "hi")
+	d := &Data{ctx: ctx, Sources: map[string]*Source{
+		"kv": {Alias: "kv", URL: mustParseURL("test+kv://example.com/foo")},
+	}}
+	actual, err := d.Datasource("kv", "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"host": "example.com"}, actual)
+	assert.Equal(t, "hi", gotCtx.Value(ctxKey{}))
+	assert.Equal(t, []string{"bar"}, gotArgs)
+
+	assert.Panics(t, func() { RegisterReader("", ReaderFunc(readEnv)) })
+	assert.Panics(t, func() { RegisterReader("merge", ReaderFunc(readEnv)) })
+	assert.Panics(t, func() { RegisterReader("test+nil", nil) })
+}
+
+func TestNewSourceHTTPDefaults(t *testing.T) {
+	cfg := &config.Config{
+		HTTP: config.HTTPConfig{Retries: 3, Timeout: time.Second},
+		DataSources: map[string]config.DataSource{
+			"foo": {URL: mustParseURL("https://example.com"), HTTP: config.HTTPConfig{Timeout: time.Minute}},
+		},
+	}
+	d := FromConfig(context.Background(), cfg)
+	assert.Equal(t, config.HTTPConfig{Retries: 3, Timeout: time.Minute}, d.Sources["foo"].httpConfig)
+
+	_, err := d.DefineDatasource("bar", "https://example.com/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, cfg.HTTP, d.Sources["bar"].httpConfig)
 }
@@ -1,47 +1,54 @@
 package data
 
 import (
+	"context"
 	"strings"
 
 	"github.com/pkg/errors"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/hairyhenderson/gomplate/v3/vault"
 )
 
-func readVault(source *Source, args ...string) (data []byte, err error) {
+func readVault(_ context.Context, source *Source, args ...string) (data []byte, mediaType string, err error) {
 	if source.vc == nil {
 		source.vc, err = vault.New(source.URL)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		err = source.vc.Login()
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	}
 
 	params, p, err := parseDatasourceURLArgs(source.URL, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	source.mediaType = jsonMimetype
+	mediaType = jsonMimetype
 	switch {
 	case len(params) > 0:
 		data, err = source.vc.Write(p, params)
 	case strings.HasSuffix(p, "/"):
-		source.mediaType = jsonArrayMimetype
+		mediaType = jsonArrayMimetype
 		data, err = source.vc.List(p)
 	default:
 		data, err = source.vc.Read(p)
 	}
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if len(data) == 0 {
-		return nil, errors.Errorf("no value found for path %s", p)
+		return nil, "", errors.Errorf("no value found for path %s", p)
 	}
 
-	return data, nil
+	if mediaType != jsonArrayMimetype {
+		// listings are just key names, but everything else is secret
+		redact.AddJSON(data)
+	}
+
+	return data, mediaType, nil
 }
@@ -1,9 +1,11 @@
 package data
 
 import (
+	"context"
 	"net/url"
 	"testing"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/hairyhenderson/gomplate/v3/vault"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,20 +22,21 @@ func TestReadVault(t *testing.T) {
 		vc:        v,
 	}
 
-	r, err := readVault(source)
+	r, mediaType, err := readVault(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(expected), r)
+	assert.Equal(t, jsonMimetype, mediaType)
 
-	r, err = readVault(source, "bar")
+	r, _, err = readVault(context.Background(), source, "bar")
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(expected), r)
 
-	r, err = readVault(source, "?param=value")
+	r, _, err = readVault(context.Background(), source, "?param=value")
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(expected), r)
 
 	source.URL, _ = url.Parse("vault:///secret/foo?param1=value1&param2=value2")
-	r, err = readVault(source)
+	r, _, err = readVault(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(expected), r)
 
@@ -41,7 +44,31 @@ func TestReadVault(t *testing.T) {
 	server, source.vc = vault.MockServer(200, `{"data":{"keys":`+expected+`}}`)
 	defer server.Close()
 	source.URL, _ = url.Parse("vault:///secret/foo/")
-	r, err = readVault(source)
+	r, mediaType, err = readVault(context.Background(), source)
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(expected), r)
+	assert.Equal(t, jsonArrayMimetype, mediaType)
+}
+
+func TestReadVault_Redacted(t *testing.T) {
+	server, v := vault.MockServer(200, `{"data":{"value":"vaultsecret"}}`)
+	defer server.Close()
+
+	source := &Source{
+		Alias: "foo",
+		URL:   &url.URL{Scheme: "vault", Path: "/secret/foo"},
+		vc:    v,
+	}
+
+	_, _, err := readVault(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, "value: ****", redact.String("value: vaultsecret"))
+
+	// listings are only key names, so they're not secret
+	server, source.vc = vault.MockServer(200, `{"data":{"keys":["vaultkey1"]}}`)
+	defer server.Close()
+	source.URL, _ = url.Parse("vault:///secret/foo/")
+	_, _, err = readVault(context.Background(), source)
+	assert.NoError(t, err)
+	assert.Equal(t, "key: vaultkey1", redact.String("key: vaultkey1"))
 }
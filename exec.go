@@ -0,0 +1,85 @@
+package gomplate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/hairyhenderson/gomplate/v3/internal/iohelpers"
+	"github.com/rs/zerolog"
+)
+
+// outputPathEnvVar is set to the output's path when running an output rule's
+// postExec command. References to it in the command's arguments are expanded
+// too, since the command isn't run in a shell.
+const outputPathEnvVar = config.OutputPathEnvVar
+
+// runOutputExec - run the postExec command configured for the template's
+// output, if the output was actually (re)written
+func runOutputExec(ctx context.Context, cfg *config.Config, t *tplate) error {
+	if t.targetPath == "-" {
+		return nil
+	}
+	rule := cfg.OutputRuleFor(t.targetPath)
+	if len(rule.PostExec) == 0 {
+		return nil
+	}
+
+	log := zerolog.Ctx(ctx)
+	if !iohelpers.Changed(t.target) {
+		log.Debug().Str("output", t.targetPath).Msg("output unchanged, skipping post-exec command")
+		return nil
+	}
+
+	args := make([]string, len(rule.PostExec))
+	for i, arg := range rule.PostExec {
+		arg = strings.ReplaceAll(arg, "${"+outputPathEnvVar+"}", t.targetPath)
+		args[i] = strings.ReplaceAll(arg, "$"+outputPathEnvVar, t.targetPath)
+	}
+	log.Debug().Str("output", t.targetPath).Strs("args", args).Msg("running output post-exec command")
+
+	// nolint: gosec
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Env = append(os.Environ(), outputPathEnvVar+"="+t.targetPath)
+	c.Stdout = cfg.Stdout
+	c.Stderr = cfg.Stderr
+
+	err := c.Run()
+	if err != nil {
+		return fmt.Errorf("post-exec command for output %s failed: %w", t.targetPath, err)
+	}
+	return nil
+}
+
+// runJobExec - run the job's postExec command, if any. With execOnChange, the
+// command is only run when the job changed at least one output. The command's
+// output is written to stdout, since with execPipe the job's standard output is
+// the command's input.
+func runJobExec(ctx context.Context, cfg *config.Config, stdout io.Writer, changed int) error {
+	if len(cfg.PostExec) == 0 {
+		return nil
+	}
+
+	log := zerolog.Ctx(ctx)
+	if cfg.ExecOnChange && changed == 0 {
+		log.Debug().Msg("no outputs changed, skipping job post-exec command")
+		return nil
+	}
+	log.Debug().Strs("args", cfg.PostExec).Msg("running job post-exec command")
+
+	// nolint: gosec
+	c := exec.CommandContext(ctx, cfg.PostExec[0], cfg.PostExec[1:]...)
+	c.Stdin = cfg.PostExecInput
+	c.Stdout = stdout
+	c.Stderr = cfg.Stderr
+
+	err := c.Run()
+	if err != nil {
+		return fmt.Errorf("post-exec command failed: %w", err)
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ import (
 
 	"github.com/hairyhenderson/gomplate/v3/data"
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/hairyhenderson/gomplate/v3/internal/iohelpers"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/afero"
@@ -32,21 +34,29 @@ type gomplate struct {
 }
 
 // runTemplate -
-func (g *gomplate) runTemplate(_ context.Context, t *tplate) error {
-	tmpl, err := t.toGoTemplate(g)
-	if err != nil {
-		return err
+func (g *gomplate) runTemplate(_ context.Context, t *tplate) (err error) {
+	switch {
+	case t.linkTarget != "":
+		// nothing to render - the symlink is created when the target's closed
+	case t.copy:
+		err = t.copyContents()
+	default:
+		var tmpl *template.Template
+		tmpl, err = t.toGoTemplate(g)
+		if err != nil {
+			return err
+		}
+
+		err = tmpl.Execute(t.target, g.tmplctx)
 	}
 
-	// nolint: gocritic
-	switch t.target.(type) {
-	case io.Closer:
-		if t.target != os.Stdout {
-			// nolint: errcheck
-			defer t.target.(io.Closer).Close()
+	// closing may flush buffered output, which can fail (e.g. validation)
+	if c, ok := t.target.(io.Closer); ok && t.target != os.Stdout {
+		cerr := c.Close()
+		if err == nil {
+			err = cerr
 		}
 	}
-	err = tmpl.Execute(t.target, g.tmplctx)
 	return err
 }
 
@@ -122,7 +132,11 @@ func RunTemplates(o *Config) error {
 }
 
 // Run all gomplate templates specified by the given configuration
-func Run(ctx context.Context, cfg *config.Config) error {
+//
+// Any secret values (read from secret-bearing datasources, or embedded in URLs)
+// are masked in the returned error.
+func Run(ctx context.Context, cfg *config.Config) (err error) {
+	defer func() { err = redact.Error(err) }()
 	log := zerolog.Ctx(ctx)
 
 	Metrics = newMetrics()
@@ -132,6 +146,53 @@ func Run(ctx context.Context, cfg *config.Config) error {
 	log.Debug().Str("data", fmt.Sprintf("%+v", d)).Msg("created data from config")
 
 	addCleanupHook(d.Cleanup)
+
+	if len(cfg.Jobs) == 0 {
+		err = run(ctx, cfg, d, nil)
+		if err != nil {
+			return err
+		}
+		warnUnusedOverrides(ctx, d)
+		return nil
+	}
+
+	// jobs share the data, so datasources are only read (and clients only
+	// logged in) once, and they share plugins, so they're only started once
+	plugins := boundPlugins{}
+	for i := range cfg.Jobs {
+		job := cfg.JobConfig(i)
+		d.SetOverrides(job.DataSourceOverrides)
+		d.AddSources(job.DataSources)
+		d.AddSources(job.Context)
+
+		log.Debug().Int("job", i).Msg("running job")
+		changed := Metrics.OutputsChanged
+		err = run(ctx, job, d, plugins)
+		if err != nil {
+			return fmt.Errorf("job %d: %w", i, err)
+		}
+		err = runJobExec(ctx, job, cfg.Stdout, Metrics.OutputsChanged-changed)
+		if err != nil {
+			Metrics.Errors++
+			return fmt.Errorf("job %d: %w", i, err)
+		}
+	}
+	warnUnusedOverrides(ctx, d)
+	return nil
+}
+
+// warnUnusedOverrides - overrides for aliases that were never read from are
+// probably typos, but that's only known once everything is rendered (aliases
+// can be defined by templates), so they're reported as a warning
+func warnUnusedOverrides(ctx context.Context, d *data.Data) {
+	if err := d.VerifyOverrides(); err != nil {
+		zerolog.Ctx(ctx).Warn().Msg(err.Error())
+	}
+}
+
+// run - render the templates specified by the given configuration, reading
+// datasources from d, and reusing the plugins already bound, if any
+func run(ctx context.Context, cfg *config.Config, d *data.Data, plugins boundPlugins) error {
 	nested, err := parseTemplateArgs(cfg.Templates)
 	if err != nil {
 		return err
@@ -141,7 +202,7 @@ func Run(ctx context.Context, cfg *config.Config) error {
 		return err
 	}
 	funcMap := CreateFuncs(ctx, d)
-	err = bindPlugins(ctx, cfg, funcMap)
+	err = bindPlugins(ctx, cfg, funcMap, plugins)
 	if err != nil {
 		return err
 	}
@@ -153,14 +214,14 @@ func Run(ctx context.Context, cfg *config.Config) error {
 func (g *gomplate) runTemplates(ctx context.Context, cfg *config.Config) error {
 	start := time.Now()
 	tmpl, err := gatherTemplates(cfg, chooseNamer(cfg, g))
-	Metrics.GatherDuration = time.Since(start)
+	Metrics.GatherDuration += time.Since(start)
 	if err != nil {
 		Metrics.Errors++
 		return fmt.Errorf("failed to gather templates for rendering: %w", err)
 	}
-	Metrics.TemplatesGathered = len(tmpl)
+	Metrics.TemplatesGathered += len(tmpl)
 	start = time.Now()
-	defer func() { Metrics.TotalRenderDuration = time.Since(start) }()
+	defer func() { Metrics.TotalRenderDuration += time.Since(start) }()
 	for _, t := range tmpl {
 		tstart := time.Now()
 		err := g.runTemplate(ctx, t)
@@ -170,6 +231,15 @@ func (g *gomplate) runTemplates(ctx context.Context, cfg *config.Config) error {
 			return fmt.Errorf("failed to render template %s: %w", t.name, err)
 		}
 		Metrics.TemplatesProcessed++
+		if iohelpers.Changed(t.target) {
+			Metrics.OutputsChanged++
+		}
+
+		err = runOutputExec(ctx, cfg, t)
+		if err != nil {
+			Metrics.Errors++
+			return err
+		}
 	}
 	return nil
 }
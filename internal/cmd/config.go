@@ -1,11 +1,19 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hairyhenderson/gomplate/v3/conv"
+	"github.com/hairyhenderson/gomplate/v3/data"
 	"github.com/hairyhenderson/gomplate/v3/env"
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
 
@@ -13,6 +21,7 @@ import (
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -24,29 +33,92 @@ var fs = afero.NewOsFs()
 // loadConfig is intended to be called before command execution. It:
 // - creates a config.Config from the cobra flags
 // - creates a config.Config from the config file (if present)
+// - applies the selected profile from the config file (if any)
 // - merges the two (flags take precedence)
 // - validates the final config
 func loadConfig(cmd *cobra.Command, args []string) (*config.Config, error) {
+	cfg, _, err := buildConfig(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate merged config: %w\n%+v", err, cfg)
+	}
+	return cfg, nil
+}
+
+// configLayers - the configs that were merged to build the final config,
+// used to find where each value came from
+type configLayers struct {
+	flags, file *yaml.Node
+	// env - the paths of the (YAML) options set from environment variables
+	env [][]string
+}
+
+// buildConfig - build the merged config (with defaults applied), without
+// validating it. The layers it was merged from are also returned.
+func buildConfig(cmd *cobra.Command, args []string) (*config.Config, *configLayers, error) {
 	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	flagConfig, err := cobraConfig(cmd, args)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cfg, err := readConfigFile(cmd)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	profile, err := pickProfile(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layers := &configLayers{}
+	// encoded before merging, since merging modifies the config
+	layers.flags, err = configNode(flagConfig)
+	if err != nil {
+		return nil, nil, err
 	}
+
 	if cfg == nil {
+		if profile != "" {
+			return nil, nil, fmt.Errorf("profile %q requested, but no config file was found", profile)
+		}
 		cfg = flagConfig
 	} else {
+		err = cfg.ApplyProfile(profile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if profile != "" {
+			zerolog.Ctx(ctx).Debug().Str("profile", profile).Msg("using config profile")
+		}
+		layers.file, err = configNode(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
 		cfg = cfg.MergeFrom(flagConfig)
 	}
 
+	before, err := configNode(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
 	cfg, err = applyEnvVars(ctx, cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	after, err := configNode(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers.env = changedPaths(before, after)
 
 	cfg.Stdin = cmd.InOrStdin()
 	cfg.Stdout = cmd.OutOrStdout()
@@ -55,52 +127,246 @@ func loadConfig(cmd *cobra.Command, args []string) (*config.Config, error) {
 	// reset defaults before validation
 	cfg.ApplyDefaults()
 
-	err = cfg.Validate()
-	if err != nil {
-		return nil, fmt.Errorf("failed to validate merged config: %w\n%+v", err, cfg)
-	}
-	return cfg, nil
+	return cfg, layers, nil
 }
 
-func pickConfigFile(cmd *cobra.Command) (cfgFile string, required bool) {
-	cfgFile = defaultConfigFile
+func pickConfigFiles(cmd *cobra.Command) (cfgFiles []string, required bool) {
+	cfgFiles = []string{defaultConfigFile}
 	if c := env.Getenv("GOMPLATE_CONFIG"); c != "" {
-		cfgFile = c
+		cfgFiles = []string{c}
 		required = true
 	}
-	if cmd.Flags().Changed("config") && cmd.Flag("config").Value.String() != "" {
-		// Use config file from the flag if specified
-		cfgFile = cmd.Flag("config").Value.String()
-		required = true
+	if cmd.Flags().Changed("config") {
+		// Use config file(s) from the flag if specified
+		if files := configFlag(cmd); len(files) > 0 {
+			cfgFiles = files
+			required = true
+		}
 	}
-	return cfgFile, required
+	return cfgFiles, required
 }
 
+// configFlag - the non-empty values of the (repeatable) --config flag
+func configFlag(cmd *cobra.Command) []string {
+	values, err := cmd.Flags().GetStringArray("config")
+	if err != nil {
+		values = []string{cmd.Flag("config").Value.String()}
+	}
+	files := []string{}
+	for _, v := range values {
+		if v != "" {
+			files = append(files, v)
+		}
+	}
+	return files
+}
+
+// pickProfile - the config profile selected with --profile or
+// $GOMPLATE_PROFILE (the flag takes precedence)
+func pickProfile(cmd *cobra.Command) (string, error) {
+	profile, err := getString(cmd, "profile")
+	if err != nil {
+		return "", err
+	}
+	if profile == "" {
+		profile = env.Getenv("GOMPLATE_PROFILE")
+	}
+	return profile, nil
+}
+
+// readConfigFile - read the config file(s), merging them in order. Files
+// included by each file are merged before it.
 func readConfigFile(cmd *cobra.Command) (cfg *config.Config, err error) {
 	ctx := cmd.Context()
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	log := zerolog.Ctx(ctx)
 
-	cfgFile, configRequired := pickConfigFile(cmd)
+	cfgFiles, configRequired := pickConfigFiles(cmd)
 
-	f, err := fs.Open(cfgFile)
+	client, err := data.NewHTTPClient(config.HTTPConfig{})
+	if err != nil {
+		return nil, err
+	}
+	strict, err := strictConfig(cmd)
 	if err != nil {
-		if configRequired {
-			return cfg, fmt.Errorf("config file requested, but couldn't be opened: %w", err)
+		return nil, err
+	}
+	l := &configLoader{ctx: ctx, client: client, strict: strict}
+	for _, cfgFile := range cfgFiles {
+		b, err := l.read(cfgFile)
+		if err != nil {
+			if configRequired {
+				return cfg, fmt.Errorf("config file requested, but couldn't be opened: %w", err)
+			}
+			continue
+		}
+
+		c, err := l.parse(cfgFile, b)
+		if err != nil {
+			if configRequired {
+				return cfg, fmt.Errorf("config file requested, but couldn't be parsed: %w", err)
+			}
+			return c, err
 		}
-		return nil, nil
+		cfg = mergeConfig(cfg, c)
+	}
+
+	return cfg, nil
+}
+
+// configLoader - loads config files, and the files they include
+type configLoader struct {
+	ctx context.Context
+	// whether unknown keys are errors, rather than warnings
+	strict bool
+	// the client for config files read from URLs
+	client *http.Client
+	// the files currently being loaded, for detecting include cycles
+	loading []string
+}
+
+// load - read and parse the config file at the given path or URL
+func (l *configLoader) load(name string) (*config.Config, error) {
+	b, err := l.read(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.parse(name, b)
+}
+
+// parse - parse the config file, and merge it over the files it includes
+func (l *configLoader) parse(name string, b []byte) (*config.Config, error) {
+	key := name
+	if !isConfigURL(name) {
+		if abs, err := filepath.Abs(name); err == nil {
+			key = abs
+		}
+	}
+	for _, f := range l.loading {
+		if f == key {
+			return nil, fmt.Errorf("config file include cycle detected: %s",
+				strings.Join(append(l.loading, key), " -> "))
+		}
+	}
+	l.loading = append(l.loading, key)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	cfg, err := config.Parse(bytes.NewReader(b))
+	var unknown *config.UnknownKeysError
+	if errors.As(err, &unknown) {
+		if l.strict {
+			return nil, fmt.Errorf("%s: %w (set --strict-config=false or GOMPLATE_STRICT_CONFIG=false to ignore)", name, err)
+		}
+		for _, k := range unknown.Keys {
+			zerolog.Ctx(l.ctx).Warn().Str("cfgFile", name).Msg(k.String())
+		}
+		err = nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	zerolog.Ctx(l.ctx).Debug().Str("cfgFile", name).Msg("using config file")
+
+	var merged *config.Config
+	for _, inc := range cfg.Include {
+		incName, err := resolveInclude(name, inc)
+		if err != nil {
+			return nil, err
+		}
+		c, err := l.load(incName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to include %s from %s: %w", inc, name, err)
+		}
+		merged = mergeConfig(merged, c)
+	}
+	cfg.Include = nil
+
+	return mergeConfig(merged, cfg), nil
+}
+
+// strictConfig - whether unknown keys in config files are errors. Set
+// --strict-config=false or $GOMPLATE_STRICT_CONFIG=false to only warn about
+// them.
+func strictConfig(cmd *cobra.Command) (bool, error) {
+	if f := cmd.Flag("strict-config"); f != nil && f.Changed {
+		return cmd.Flags().GetBool("strict-config")
+	}
+	return conv.ToBool(env.Getenv("GOMPLATE_STRICT_CONFIG", "true")), nil
+}
+
+// read - read the config file from the filesystem, or from a URL
+func (l *configLoader) read(name string) ([]byte, error) {
+	if !isConfigURL(name) {
+		return afero.ReadFile(fs, name)
 	}
 
-	cfg, err = config.Parse(f)
-	if err != nil && configRequired {
-		return cfg, fmt.Errorf("config file requested, but couldn't be parsed: %w", err)
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return afero.ReadFile(fs, u.Path)
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("unsupported config file URL scheme %q", u.Scheme)
 	}
 
-	log.Debug().Str("cfgFile", cfgFile).Msg("using config file")
+	req, err := http.NewRequestWithContext(l.ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", name, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// isConfigURL - whether the config file name is a URL rather than a path.
+// Single-letter schemes are Windows drive letters.
+func isConfigURL(name string) bool {
+	u, err := url.Parse(name)
+	return err == nil && len(u.Scheme) > 1
+}
+
+// resolveInclude - resolve the included file relative to the file that
+// includes it
+func resolveInclude(parent, inc string) (string, error) {
+	if isConfigURL(inc) {
+		return inc, nil
+	}
+	if isConfigURL(parent) {
+		base, err := url.Parse(parent)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(filepath.ToSlash(inc))
+		if err != nil {
+			return "", fmt.Errorf("invalid include %q: %w", inc, err)
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	if filepath.IsAbs(inc) {
+		return inc, nil
+	}
+	return filepath.Join(filepath.Dir(parent), inc), nil
+}
 
-	return cfg, err
+// mergeConfig - merge o over c, either of which may be nil
+func mergeConfig(c, o *config.Config) *config.Config {
+	if c == nil {
+		return o
+	}
+	if o == nil {
+		return c
+	}
+	return c.MergeFrom(o)
 }
 
 // cobraConfig - initialize a config from the commandline options
@@ -130,6 +396,19 @@ func cobraConfig(cmd *cobra.Command, args []string) (cfg *config.Config, err err
 	// support --include
 	cfg.ExcludeGlob = processIncludes(includesFlag, cfg.ExcludeGlob)
 
+	cfg.CopyGlob, err = getStringSlice(cmd, "copy")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Symlinks, err = getString(cmd, "symlinks")
+	if err != nil {
+		return nil, err
+	}
+	cfg.RewriteSymlinks, err = getBool(cmd, "rewrite-symlinks")
+	if err != nil {
+		return nil, err
+	}
+
 	cfg.OutputFiles, err = getStringSlice(cmd, "out")
 	if err != nil {
 		return nil, err
@@ -150,6 +429,31 @@ func cobraConfig(cmd *cobra.Command, args []string) (cfg *config.Config, err err
 	if err != nil {
 		return nil, err
 	}
+	cfg.Chown, err = getString(cmd, "chown")
+	if err != nil {
+		return nil, err
+	}
+	cfg.InheritOwner, err = getBool(cmd, "inherit-owner")
+	if err != nil {
+		return nil, err
+	}
+	cfg.DirMode, err = getString(cmd, "dir-mode")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.StripBOM, err = getBool(cmd, "strip-bom")
+	if err != nil {
+		return nil, err
+	}
+	cfg.LineEndings, err = getString(cmd, "line-endings")
+	if err != nil {
+		return nil, err
+	}
+	cfg.OutputCharset, err = getString(cmd, "output-charset")
+	if err != nil {
+		return nil, err
+	}
 
 	if len(args) > 0 {
 		cfg.PostExec = args
@@ -159,6 +463,10 @@ func cobraConfig(cmd *cobra.Command, args []string) (cfg *config.Config, err err
 	if err != nil {
 		return nil, err
 	}
+	cfg.ExecOnChange, err = getBool(cmd, "exec-on-change")
+	if err != nil {
+		return nil, err
+	}
 	cfg.Experimental, err = getBool(cmd, "experimental")
 	if err != nil {
 		return nil, err
@@ -190,6 +498,28 @@ func cobraConfig(cmd *cobra.Command, args []string) (cfg *config.Config, err err
 		return nil, err
 	}
 
+	ovr, err := getStringArray(cmd, "datasource-override")
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.ParseDataSourceOverrideFlags(ovr)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.CacheDir, err = getString(cmd, "cache-dir")
+	if err != nil {
+		return nil, err
+	}
+	cfg.CacheTTL, err = getDuration(cmd, "cache-ttl")
+	if err != nil {
+		return nil, err
+	}
+	cfg.Offline, err = getBool(cmd, "offline")
+	if err != nil {
+		return nil, err
+	}
+
 	pl, err := getStringSlice(cmd, "plugin")
 	if err != nil {
 		return nil, err
@@ -208,6 +538,13 @@ func getStringSlice(cmd *cobra.Command, flag string) (s []string, err error) {
 	return s, err
 }
 
+func getStringArray(cmd *cobra.Command, flag string) (s []string, err error) {
+	if cmd.Flag(flag) != nil && cmd.Flag(flag).Changed {
+		s, err = cmd.Flags().GetStringArray(flag)
+	}
+	return s, err
+}
+
 func getString(cmd *cobra.Command, flag string) (s string, err error) {
 	if cmd.Flag(flag) != nil && cmd.Flag(flag).Changed {
 		s, err = cmd.Flags().GetString(flag)
@@ -222,6 +559,13 @@ func getBool(cmd *cobra.Command, flag string) (b bool, err error) {
 	return b, err
 }
 
+func getDuration(cmd *cobra.Command, flag string) (d time.Duration, err error) {
+	if cmd.Flag(flag) != nil && cmd.Flag(flag).Changed {
+		d, err = cmd.Flags().GetDuration(flag)
+	}
+	return d, err
+}
+
 // process --include flags - these are analogous to specifying --exclude '*',
 // then the inverse of the --include options.
 func processIncludes(includes, excludes []string) []string {
@@ -260,6 +604,13 @@ func applyEnvVars(ctx context.Context, cfg *config.Config) (*config.Config, erro
 		cfg.Experimental = true
 	}
 
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = env.Getenv("GOMPLATE_CACHE_DIR")
+	}
+	if !cfg.Offline && conv.ToBool(env.Getenv("GOMPLATE_OFFLINE", "false")) {
+		cfg.Offline = true
+	}
+
 	if cfg.LDelim == "" {
 		cfg.LDelim = env.Getenv("GOMPLATE_LEFT_DELIM")
 	}
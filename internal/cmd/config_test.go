@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/hairyhenderson/gomplate/v3/data"
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
 
 	"github.com/spf13/afero"
@@ -171,29 +175,229 @@ func TestProcessIncludes(t *testing.T) {
 	}
 }
 
-func TestPickConfigFile(t *testing.T) {
+func TestPickConfigFiles(t *testing.T) {
 	cmd := &cobra.Command{}
-	cmd.Flags().String("config", defaultConfigFile, "foo")
+	cmd.Flags().StringArray("config", []string{defaultConfigFile}, "foo")
 
-	cf, req := pickConfigFile(cmd)
+	cf, req := pickConfigFiles(cmd)
 	assert.False(t, req)
-	assert.Equal(t, defaultConfigFile, cf)
+	assert.Equal(t, []string{defaultConfigFile}, cf)
 
 	os.Setenv("GOMPLATE_CONFIG", "foo.yaml")
 	defer os.Unsetenv("GOMPLATE_CONFIG")
-	cf, req = pickConfigFile(cmd)
+	cf, req = pickConfigFiles(cmd)
 	assert.True(t, req)
-	assert.Equal(t, "foo.yaml", cf)
+	assert.Equal(t, []string{"foo.yaml"}, cf)
 
 	cmd.ParseFlags([]string{"--config", "config.file"})
-	cf, req = pickConfigFile(cmd)
+	cf, req = pickConfigFiles(cmd)
 	assert.True(t, req)
-	assert.Equal(t, "config.file", cf)
+	assert.Equal(t, []string{"config.file"}, cf)
 
 	os.Setenv("GOMPLATE_CONFIG", "ignored.yaml")
-	cf, req = pickConfigFile(cmd)
+	cf, req = pickConfigFiles(cmd)
+	assert.True(t, req)
+	assert.Equal(t, []string{"config.file"}, cf)
+
+	cmd = &cobra.Command{}
+	cmd.Flags().StringArray("config", []string{defaultConfigFile}, "foo")
+	cmd.ParseFlags([]string{"--config", "base.yaml", "--config", "", "--config", "local.yaml"})
+	cf, req = pickConfigFiles(cmd)
 	assert.True(t, req)
-	assert.Equal(t, "config.file", cf)
+	assert.Equal(t, []string{"base.yaml", "local.yaml"}, cf)
+}
+
+func TestReadConfigFileIncludes(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	writeFile := func(name, content string) {
+		t.Helper()
+		assert.NoError(t, afero.WriteFile(fs, name, []byte(content), 0o644))
+	}
+	writeFile("shared/base.yaml", `include: [plugins.yaml]
+leftDelim: '(('
+plugins:
+  foo: base-foo
+`)
+	writeFile("shared/plugins.yaml", `plugins:
+  foo: shared-foo
+  bar: shared-bar
+`)
+	writeFile("local.yaml", `include: [shared/base.yaml]
+in: hello
+plugins:
+  bar: local-bar
+`)
+	writeFile("other.yaml", `rightDelim: '))'
+in: goodbye
+`)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringArray("config", []string{defaultConfigFile}, "foo")
+	cmd.ParseFlags([]string{"--config", "local.yaml", "--config", "other.yaml"})
+
+	cfg, err := readConfigFile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, &config.Config{
+		Input:  "goodbye",
+		LDelim: "((",
+		RDelim: "))",
+		Plugins: map[string]config.PluginConfig{
+			"foo": {Cmd: "base-foo"},
+			"bar": {Cmd: "local-bar"},
+		},
+	}, cfg)
+
+	// cycles are detected
+	writeFile("shared/plugins.yaml", "include: [../local.yaml]\n")
+	_, err = readConfigFile(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "config file include cycle detected: "+
+		filepath.Join(wd, "local.yaml")+" -> "+
+		filepath.Join(wd, "shared", "base.yaml")+" -> "+
+		filepath.Join(wd, "shared", "plugins.yaml")+" -> "+
+		filepath.Join(wd, "local.yaml"))
+
+	writeFile("shared/plugins.yaml", "include: [missing.yaml]\n")
+	_, err = readConfigFile(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to include missing.yaml from "+filepath.Join("shared", "plugins.yaml"))
+}
+
+func TestReadConfigFileUnknownKeys(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+	assert.NoError(t, afero.WriteFile(fs, defaultConfigFile, []byte("in: hello\noutputdir: out\n"), 0o644))
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("strict-config", true, "")
+
+	// unknown keys are errors by default
+	_, err := readConfigFile(cmd)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown key "outputdir"`)
+
+	t.Setenv("GOMPLATE_STRICT_CONFIG", "false")
+	cfg, err := readConfigFile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", cfg.Input)
+
+	// the flag takes precedence over the environment
+	t.Setenv("GOMPLATE_STRICT_CONFIG", "true")
+	assert.NoError(t, cmd.ParseFlags([]string{"--strict-config=false"}))
+	cfg, err = readConfigFile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", cfg.Input)
+}
+
+func TestConfigLoaderReadURL(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.yaml" {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		fmt.Fprint(w, "in: hello\n")
+	}))
+	defer srv.Close()
+
+	client, err := data.NewHTTPClient(config.HTTPConfig{Timeout: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	l := &configLoader{ctx: context.Background(), client: client}
+
+	b, err := l.read(srv.URL + "/config.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, "in: hello\n", string(b))
+
+	// slow servers time out instead of hanging
+	_, err = l.read(srv.URL + "/slow.yaml")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Client.Timeout exceeded")
+}
+
+func TestResolveInclude(t *testing.T) {
+	data := []struct {
+		parent, inc, expected string
+	}{
+		{"config.yaml", "base.yaml", "base.yaml"},
+		{filepath.Join("a", "config.yaml"), "base.yaml", filepath.Join("a", "base.yaml")},
+		{filepath.Join("a", "config.yaml"), filepath.Join("..", "base.yaml"), "base.yaml"},
+		{"config.yaml", "https://example.com/base.yaml", "https://example.com/base.yaml"},
+		{"https://example.com/cfg/config.yaml", "base.yaml", "https://example.com/cfg/base.yaml"},
+		{"https://example.com/cfg/config.yaml", "../base.yaml", "https://example.com/base.yaml"},
+		{"https://example.com/cfg/config.yaml", "/base.yaml", "https://example.com/base.yaml"},
+	}
+	for _, d := range data {
+		out, err := resolveInclude(d.parent, d.inc)
+		assert.NoError(t, err)
+		assert.Equal(t, d.expected, out)
+	}
+}
+
+func TestPickProfile(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("profile", "", "foo")
+
+	p, err := pickProfile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "", p)
+
+	t.Setenv("GOMPLATE_PROFILE", "staging")
+	p, err = pickProfile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "staging", p)
+
+	cmd.ParseFlags([]string{"--profile", "prod"})
+	p, err = pickProfile(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", p)
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	fs = afero.NewMemMapFs()
+	defer func() { fs = afero.NewOsFs() }()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", defaultConfigFile, "...")
+	cmd.Flags().String("profile", "", "...")
+	cmd.Flags().String("in", "", "...")
+	cmd.Flags().String("output-dir", ".", "...")
+
+	cmd.ParseFlags([]string{"--profile", "prod"})
+	_, err := loadConfig(cmd, cmd.Flags().Args())
+	assert.EqualError(t, err, `profile "prod" requested, but no config file was found`)
+
+	f, err := fs.Create(defaultConfigFile)
+	assert.NoError(t, err)
+	f.WriteString(`inputDir: in
+outputDir: out/dev
+profiles:
+  prod:
+    outputDir: out/prod
+`)
+
+	out, err := loadConfig(cmd, cmd.Flags().Args())
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", out.Profile)
+	assert.Equal(t, "out/prod", out.OutputDir)
+
+	// flags override the profile
+	cmd.ParseFlags([]string{"--profile", "prod", "--output-dir", "out/local"})
+	out, err = loadConfig(cmd, cmd.Flags().Args())
+	assert.NoError(t, err)
+	assert.Equal(t, "out/local", out.OutputDir)
+
+	cmd.ParseFlags([]string{"--profile", "bogus"})
+	_, err = loadConfig(cmd, cmd.Flags().Args())
+	assert.Error(t, err)
 }
 
 func TestApplyEnvVars(t *testing.T) {
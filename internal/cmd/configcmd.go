@@ -0,0 +1,395 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/data"
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Where config values come from
+const (
+	originFlag    = "flag"
+	originEnv     = "env"
+	originFile    = "file"
+	originDefault = "default"
+)
+
+// newConfigCmd - the `config` command, for inspecting the effective config
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show or validate the effective configuration, or print its schema",
+		Args:  cobra.NoArgs,
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration, with the origin of each value",
+		Long: `Print the effective configuration, merged from the commandline flags,
+environment variables, and config file(s), as YAML. Each value is annotated
+with where it came from: 'flag', 'env', 'file', or 'default'. Credentials in
+URLs, header values, request bodies, and other known secrets are masked,
+unless --show-secrets is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, layers, err := buildConfig(cmd, args)
+			if err != nil {
+				return err
+			}
+			showSecrets, err := cmd.Flags().GetBool("show-secrets")
+			if err != nil {
+				return err
+			}
+			out, err := showConfig(cfg, layers, showSecrets)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprint(cmd.OutOrStdout(), out)
+			return err
+		},
+		Args: optionalExecArgs,
+	}
+	InitFlags(showCmd)
+	showCmd.Flags().Bool("show-secrets", false, "Show credentials in URLs and header values, instead of masking them")
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the effective configuration for errors",
+		Long: `Check the effective configuration for errors. As well as the checks made
+before rendering, datasource URLs must have supported schemes, and plugin
+commands must exist.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := buildConfig(cmd, args)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			problems := validateConfig(cfg)
+			if len(problems) > 0 {
+				return fmt.Errorf("found %d problem(s) with the config:\n  - %s",
+					len(problems), strings.Join(problems, "\n  - "))
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), "config is valid")
+			return err
+		},
+		Args: optionalExecArgs,
+	}
+	InitFlags(validateCmd)
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema for the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b, err := config.Schema()
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(b)
+			return err
+		},
+	}
+
+	configCmd.AddCommand(showCmd, validateCmd, schemaCmd)
+	return configCmd
+}
+
+// showConfig - encode the config as YAML, annotating each value with its
+// origin. Secrets are masked unless showSecrets is set.
+func showConfig(cfg *config.Config, layers *configLayers, showSecrets bool) (string, error) {
+	n, err := configNode(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		switch key.Value {
+		case "datasources", "context", "datasourceOverrides", "plugins":
+			// these are merged by name, so each may come from somewhere else
+			if val.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(val.Content); j += 2 {
+					layers.annotateTree(val.Content[j], val.Content[j+1], key.Value, val.Content[j].Value)
+				}
+				continue
+			}
+		}
+		layers.annotateTree(key, val, key.Value)
+	}
+
+	if !showSecrets {
+		addSecrets(n)
+		redactNode(n)
+	}
+
+	out := &strings.Builder{}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	err = enc.Encode(n)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// annotate - add a comment to the key, or to its value when the value is
+// written on the same line
+func annotate(key, val *yaml.Node, comment string) {
+	if val.Kind == yaml.ScalarNode || val.Style&yaml.FlowStyle != 0 {
+		val.LineComment = comment
+		return
+	}
+	key.LineComment = comment
+}
+
+// annotateTree - annotate the value at the given path with its origin, or
+// annotate its values separately when only some were set from environment
+// variables
+func (l *configLayers) annotateTree(key, val *yaml.Node, path ...string) {
+	if val.Kind == yaml.MappingNode && !l.fromEnv(path) && l.envBelow(path) {
+		for i := 0; i+1 < len(val.Content); i += 2 {
+			l.annotateTree(val.Content[i], val.Content[i+1], append(path[:len(path):len(path)], val.Content[i].Value)...)
+		}
+		return
+	}
+	annotate(key, val, l.origin(val, path...))
+}
+
+// fromEnv - whether the value at the path, or one containing it, was set from
+// an environment variable
+func (l *configLayers) fromEnv(path []string) bool {
+	for _, p := range l.env {
+		if len(p) <= len(path) && pathHasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// envBelow - whether any values inside the value at the path were set from
+// environment variables
+func (l *configLayers) envBelow(path []string) bool {
+	for _, p := range l.env {
+		if len(p) > len(path) && pathHasPrefix(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathHasPrefix(path, prefix []string) bool {
+	for i, k := range prefix {
+		if path[i] != k {
+			return false
+		}
+	}
+	return true
+}
+
+// origin - where the value at the given path came from. Values may be
+// changed by later layers (or by defaults), so the first layer with the
+// same value is preferred.
+func (l *configLayers) origin(val *yaml.Node, path ...string) string {
+	flag := lookupNode(l.flags, path...)
+	file := lookupNode(l.file, path...)
+	env := l.fromEnv(path)
+	switch {
+	case sameNode(flag, val):
+		return originFlag
+	case env:
+		return originEnv
+	case sameNode(file, val):
+		return originFile
+	// maps merged from more than one layer
+	case flag != nil && val.Kind == yaml.MappingNode:
+		return originFlag
+	case file != nil && val.Kind == yaml.MappingNode:
+		return originFile
+	default:
+		return originDefault
+	}
+}
+
+// validateConfig - validate the config and its jobs, returning any problems
+func validateConfig(cfg *config.Config) []string {
+	problems := []string{}
+	seen := map[string]bool{}
+	add := func(format string, args ...interface{}) {
+		p := fmt.Sprintf(format, args...)
+		if !seen[p] {
+			seen[p] = true
+			problems = append(problems, p)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		add("%v", err)
+	}
+
+	// jobs inherit the top-level datasources and plugins, but may add others
+	configs := []*config.Config{cfg}
+	for i := range cfg.Jobs {
+		configs = append(configs, cfg.JobConfig(i))
+	}
+	for _, c := range configs {
+		for kind, sources := range map[string]map[string]config.DataSource{
+			"datasource":          c.DataSources,
+			"context":             c.Context,
+			"datasource override": c.DataSourceOverrides,
+		} {
+			for alias, ds := range sources {
+				switch {
+				case ds.URL == nil:
+					add("%s %q has no URL", kind, alias)
+				case kind == "datasource override" && ds.URL.Scheme == "data":
+					// inline override values
+				case !data.SupportedScheme(ds.URL.Scheme):
+					add("%s %q has unsupported URL scheme %q", kind, alias, ds.URL.Scheme)
+				}
+			}
+		}
+		for name, p := range c.Plugins {
+			if err := checkPluginCmd(p); err != nil {
+				add("plugin %q: %v", name, err)
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// checkPluginCmd - check that the plugin's command (or manifest, or module)
+// exists
+func checkPluginCmd(p config.PluginConfig) error {
+	if config.IsPluginManifest(p.Cmd) || p.GetProtocol() == config.PluginProtocolWasm {
+		_, err := fs.Stat(p.Cmd)
+		return err
+	}
+	_, err := exec.LookPath(p.Cmd)
+	return err
+}
+
+// configNode - encode the config as a YAML mapping node
+func configNode(cfg *config.Config) (*yaml.Node, error) {
+	n := &yaml.Node{}
+	err := n.Encode(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return n, nil
+}
+
+// lookupNode - find the value at the given path of mapping keys, or nil
+func lookupNode(n *yaml.Node, path ...string) *yaml.Node {
+	for _, key := range path {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				next = n.Content[i+1]
+				break
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+// sameNode - whether the nodes encode the same value
+func sameNode(a, b *yaml.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ab, err := yaml.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := yaml.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(ab) == string(bb)
+}
+
+// changedPaths - the paths of the values that differ between the two mapping
+// nodes. Mappings in both are compared value by value, so only the values
+// inside them that changed are included.
+func changedPaths(before, after *yaml.Node, prefix ...string) [][]string {
+	changed := [][]string{}
+	for i := 0; i+1 < len(after.Content); i += 2 {
+		key, val := after.Content[i].Value, after.Content[i+1]
+		path := append(prefix[:len(prefix):len(prefix)], key)
+		prev := lookupNode(before, key)
+		switch {
+		case sameNode(prev, val):
+		case prev != nil && prev.Kind == yaml.MappingNode && val.Kind == yaml.MappingNode:
+			changed = append(changed, changedPaths(prev, val, path...)...)
+		default:
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+// addSecrets - register the credentials in URLs, and the values of headers
+// and request bodies, anywhere in the node as secrets, so that they're masked
+// in the same way as in logs and errors
+func addSecrets(n *yaml.Node) {
+	if n.Kind != yaml.MappingNode {
+		for _, c := range n.Content {
+			addSecrets(c)
+		}
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i].Value, n.Content[i+1]
+		switch {
+		case (key == "url" || key == "proxy") && val.Kind == yaml.ScalarNode:
+			if u, err := url.Parse(val.Value); err == nil {
+				redact.AddURL(u)
+			}
+		case key == "header" && val.Kind == yaml.MappingNode:
+			// header names aren't secret
+			for j := 1; j < len(val.Content); j += 2 {
+				addScalars(val.Content[j])
+			}
+		case key == "body" && val.Kind == yaml.ScalarNode:
+			redact.Add(val.Value)
+		default:
+			addSecrets(val)
+		}
+	}
+}
+
+func addScalars(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		redact.Add(n.Value)
+		return
+	}
+	for _, c := range n.Content {
+		addScalars(c)
+	}
+}
+
+// redactNode - mask all registered secrets in the node's values
+func redactNode(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode {
+		n.Value = redact.String(n.Value)
+		return
+	}
+	for _, c := range n.Content {
+		redactNode(c)
+	}
+}
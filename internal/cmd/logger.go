@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/hairyhenderson/gomplate/v3/env"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/rs/zerolog"
 	zlog "github.com/rs/zerolog/log"
 	"golang.org/x/term"
@@ -43,7 +44,10 @@ func fmtField(fname string) func(i interface{}) string {
 func createLogger(format string, out io.Writer) zerolog.Logger {
 	zerolog.MessageFieldName = "msg"
 
-	l := zlog.Logger.Output(out)
+	// mask secrets in all log output - out is kept for terminal detection
+	rw := redact.Writer(out)
+
+	l := zlog.Logger.Output(rw)
 
 	stdlogger := l.With().Bool("stdlog", true).Logger()
 	stdlog.SetFlags(0)
@@ -55,18 +59,18 @@ func createLogger(format string, out io.Writer) zerolog.Logger {
 			useColour = true
 		}
 		l = l.Output(zerolog.ConsoleWriter{
-			Out:        out,
+			Out:        rw,
 			NoColor:    !useColour,
 			TimeFormat: "15:04:05",
 		})
 		stdlogger = stdlogger.Output(zerolog.ConsoleWriter{
-			Out:         out,
+			Out:         rw,
 			NoColor:     !useColour,
 			FormatLevel: func(i interface{}) string { return "" },
 		})
 	case "logfmt":
 		w := zerolog.ConsoleWriter{
-			Out:             out,
+			Out:             rw,
 			NoColor:         true,
 			FormatMessage:   fmtField(zerolog.MessageFieldName),
 			FormatLevel:     fmtField(zerolog.LevelFieldName),
@@ -76,7 +80,7 @@ func createLogger(format string, out io.Writer) zerolog.Logger {
 		stdlogger = stdlogger.Output(w)
 	case "simple":
 		w := zerolog.ConsoleWriter{
-			Out:             out,
+			Out:             rw,
 			NoColor:         true,
 			FormatLevel:     func(i interface{}) string { return "" },
 			FormatTimestamp: func(i interface{}) string { return "" },
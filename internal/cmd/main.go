@@ -106,17 +106,28 @@ func NewGomplateCmd() *cobra.Command {
 			cmd.SilenceUsage = true
 
 			log.Debug().Int("templatesRendered", gomplate.Metrics.TemplatesProcessed).
+				Int("outputsChanged", gomplate.Metrics.OutputsChanged).
 				Int("errors", gomplate.Metrics.Errors).
 				Dur("duration", gomplate.Metrics.TotalRenderDuration).
+				Interface("pluginCalls", gomplate.Metrics.PluginCalls).
+				Interface("pluginCacheHits", gomplate.Metrics.PluginCacheHits).
+				Interface("pluginTimeouts", gomplate.Metrics.PluginTimeouts).
 				Msg("completed rendering")
 
 			if err != nil {
 				return err
 			}
+			if cfg.ExecOnChange && gomplate.Metrics.OutputsChanged == 0 {
+				log.Debug().Msg("no outputs changed, skipping post-exec command")
+				return nil
+			}
 			return postRunExec(ctx, cfg, cmd.OutOrStdout(), cmd.ErrOrStderr())
 		},
 		Args: optionalExecArgs,
 	}
+	// only the config command is needed
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	rootCmd.AddCommand(newConfigCmd())
 	return rootCmd
 }
 
@@ -130,6 +141,12 @@ func InitFlags(command *cobra.Command) {
 	command.Flags().StringSliceP("datasource", "d", nil, "`datasource` in alias=URL form. Specify multiple times to add multiple sources.")
 	command.Flags().StringSliceP("datasource-header", "H", nil, "HTTP `header` field in 'alias=Name: value' form to be provided on HTTP-based data sources. Multiples can be set.")
 
+	command.Flags().StringArray("datasource-override", nil, "replace the URL of the named `datasource` (or context) in alias=URL form, for offline use or testing. Specify multiple times to override multiple sources.")
+
+	command.Flags().String("cache-dir", "", "`directory` to cache remote datasources in between runs [$GOMPLATE_CACHE_DIR]")
+	command.Flags().Duration("cache-ttl", 0, "how long to cache remote datasources for, unless set on the datasource (requires --cache-dir)")
+	command.Flags().Bool("offline", false, "read remote datasources only from the cache (requires --cache-dir, and a TTL from --cache-ttl or on a datasource) [$GOMPLATE_OFFLINE]")
+
 	command.Flags().StringSliceP("context", "c", nil, "pre-load a `datasource` into the context, in alias=URL form. Use the special alias `.` to set the root context.")
 
 	command.Flags().StringSlice("plugin", nil, "plug in an external command as a function in name=path form. Can be specified multiple times")
@@ -140,14 +157,25 @@ func InitFlags(command *cobra.Command) {
 
 	command.Flags().StringSlice("exclude", []string{}, "glob of files to not parse")
 	command.Flags().StringSlice("include", []string{}, "glob of files to parse")
+	command.Flags().StringSlice("copy", []string{}, "glob of files to copy verbatim instead of rendering. Only used for --input-dir")
+	command.Flags().String("symlinks", "", "how to handle symlinks in --input-dir: `mode` 'follow', 'preserve', or 'skip'. Omit to follow symlinks to files only")
+	command.Flags().Bool("rewrite-symlinks", false, "with --symlinks=preserve, rewrite link targets in the input directory to point to the matching output")
 
 	command.Flags().StringSliceP("out", "o", []string{"-"}, "output `file` name. Omit to use standard output.")
 	command.Flags().StringSliceP("template", "t", []string{}, "Additional template file(s)")
 	command.Flags().String("output-dir", ".", "`directory` to store the processed templates. Only used for --input-dir")
 	command.Flags().String("output-map", "", "Template `string` to map the input file to an output path")
 	command.Flags().String("chmod", "", "set the mode for output file(s). Omit to inherit from input file(s)")
+	command.Flags().String("chown", "", "set the owner and/or group of output file(s), in `user:group` form")
+	command.Flags().Bool("inherit-owner", false, "give output file(s) the same owner and group as the input file(s)")
+	command.Flags().String("dir-mode", "", "set the `mode` for any output directories that are created. Omit to inherit from the input directory")
+
+	command.Flags().Bool("strip-bom", false, "strip UTF-8 byte-order marks from input templates")
+	command.Flags().String("line-endings", "", "normalise line endings in output file(s) to `style` 'lf' or 'crlf'. Omit to leave unchanged")
+	command.Flags().String("output-charset", "", "transcode output file(s) to the given `charset` (e.g. 'latin1', 'UTF-16'). Omit for UTF-8")
 
 	command.Flags().Bool("exec-pipe", false, "pipe the output to the post-run exec command")
+	command.Flags().Bool("exec-on-change", false, "only run the post-run exec command if at least one output changed")
 
 	// these are only set for the help output - these defaults aren't actually used
 	ldDefault := env.Getenv("GOMPLATE_LEFT_DELIM", "{{")
@@ -159,7 +187,9 @@ func InitFlags(command *cobra.Command) {
 
 	command.Flags().BoolP("verbose", "V", false, "output extra information about what gomplate is doing")
 
-	command.Flags().String("config", defaultConfigFile, "config file (overridden by commandline flags)")
+	command.Flags().StringArray("config", []string{defaultConfigFile}, "config `file` (overridden by commandline flags). Specify multiple times to merge multiple files, in order")
+	command.Flags().String("profile", "", "`name` of the config file profile to apply [$GOMPLATE_PROFILE]")
+	command.Flags().Bool("strict-config", true, "fail on unknown keys in config files - set to false to only warn about them [$GOMPLATE_STRICT_CONFIG]")
 }
 
 // Main -
@@ -10,23 +10,44 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hairyhenderson/gomplate/v3/internal/iohelpers"
+	"github.com/hairyhenderson/gomplate/v3/internal/redact"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 )
 
-// Parse a config file
+// Parse a config file. References to environment variables in values, in the
+// form `${VAR}` or `${VAR:-default}`, are expanded.
+//
+// If the file contains keys that aren't known options, the config is still
+// parsed, but an *UnknownKeysError is returned.
 func Parse(in io.Reader) (*Config, error) {
 	out := &Config{}
 	dec := yaml.NewDecoder(in)
-	err := dec.Decode(out)
-	if err != nil && err != io.EOF {
+	n := &yaml.Node{}
+	err := dec.Decode(n)
+	if err == io.EOF {
+		return out, nil
+	}
+	if err != nil {
+		return out, err
+	}
+	err = expandNode(n)
+	if err != nil {
 		return out, err
 	}
+	err = n.Decode(out)
+	if err != nil {
+		return out, err
+	}
+	if keys := unknownKeys(n); len(keys) > 0 {
+		return out, &UnknownKeysError{Keys: keys}
+	}
 	return out, nil
 }
 
@@ -43,20 +64,56 @@ type Config struct {
 	InputDir    string   `yaml:"inputDir,omitempty"`
 	InputFiles  []string `yaml:"inputFiles,omitempty,flow"`
 	ExcludeGlob []string `yaml:"excludes,omitempty"`
+	CopyGlob    []string `yaml:"copy,omitempty"`
+
+	// How symlinks found in InputDir are handled - one of 'follow',
+	// 'preserve', or 'skip'. When unset, symlinks to files are followed and
+	// symlinks to directories are ignored.
+	Symlinks        string `yaml:"symlinks,omitempty"`
+	RewriteSymlinks bool   `yaml:"rewriteSymlinks,omitempty"`
 
 	OutputDir   string   `yaml:"outputDir,omitempty"`
 	OutputMap   string   `yaml:"outputMap,omitempty"`
 	OutputFiles []string `yaml:"outputFiles,omitempty,flow"`
 	OutMode     string   `yaml:"chmod,omitempty"`
 
+	// Ownership settings applied to all outputs, unless overridden by a
+	// matching entry in OutputRules
+	Chown        string `yaml:"chown,omitempty"`
+	InheritOwner bool   `yaml:"inheritOwner,omitempty"`
+	DirMode      string `yaml:"dirMode,omitempty"`
+
+	// Encoding settings applied to all inputs/outputs, unless overridden by
+	// a matching entry in OutputRules
+	StripBOM      bool   `yaml:"stripBOM,omitempty"`
+	LineEndings   string `yaml:"lineEndings,omitempty"`
+	OutputCharset string `yaml:"outputCharset,omitempty"`
+
+	OutputRules []OutputRule `yaml:"outputRules,omitempty"`
+
 	LDelim string `yaml:"leftDelim,omitempty"`
 	RDelim string `yaml:"rightDelim,omitempty"`
 
 	PostExec []string `yaml:"postExec,omitempty,flow"`
 
-	DataSources map[string]DataSource `yaml:"datasources,omitempty"`
-	Context     map[string]DataSource `yaml:"context,omitempty"`
-	Plugins     map[string]string     `yaml:"plugins,omitempty"`
+	DataSources map[string]DataSource   `yaml:"datasources,omitempty"`
+	Context     map[string]DataSource   `yaml:"context,omitempty"`
+	Plugins     map[string]PluginConfig `yaml:"plugins,omitempty"`
+
+	// Replacement URLs for datasources (or context) defined elsewhere, keyed
+	// by alias. Useful for offline runs and local testing.
+	DataSourceOverrides map[string]DataSource `yaml:"datasourceOverrides,omitempty"`
+
+	// Directory to cache remote datasources in across runs, for the TTL set
+	// on each datasource (or CacheTTL). Caching is disabled when unset.
+	CacheDir string        `yaml:"cacheDir,omitempty"`
+	CacheTTL time.Duration `yaml:"cacheTTL,omitempty"`
+	// Offline - read remote datasources only from the cache
+	Offline bool `yaml:"offline,omitempty"`
+
+	// Default HTTP client options for http and https datasources, which can
+	// be overridden by each datasource
+	HTTP HTTPConfig `yaml:"http,omitempty"`
 
 	// Extra HTTP headers not attached to pre-defined datsources. Potentially
 	// used by datasources defined in the template.
@@ -67,10 +124,32 @@ type Config struct {
 	PluginTimeout time.Duration `yaml:"pluginTimeout,omitempty"`
 
 	ExecPipe      bool `yaml:"execPipe,omitempty"`
+	ExecOnChange  bool `yaml:"execOnChange,omitempty"`
 	SuppressEmpty bool `yaml:"suppressEmpty,omitempty"`
 	Experimental  bool `yaml:"experimental,omitempty"`
+
+	// Other config files (paths or URLs) to merge this one over. Relative
+	// paths are relative to this file.
+	Include []string `yaml:"include,omitempty"`
+
+	// Sets of templates to render in the same run, each with its own inputs
+	// and outputs. Other options are inherited from this config.
+	Jobs []*Config `yaml:"jobs,omitempty"`
+
+	// Named overlays for this config, selected with --profile. Only the
+	// values set in the profile override the base config.
+	Profiles map[string]*Config `yaml:"profiles,omitempty"`
+	// Profile - the name of the profile that was applied, if any
+	Profile string `yaml:"-"`
 }
 
+// Modes for handling symlinks in the input directory
+const (
+	SymlinksFollow   = "follow"
+	SymlinksPreserve = "preserve"
+	SymlinksSkip     = "skip"
+)
+
 var cfgContextKey = struct{}{}
 
 // ContextWithConfig returns a new context with a reference to the config.
@@ -92,6 +171,136 @@ func FromContext(ctx context.Context) (cfg *Config) {
 	return cfg
 }
 
+// OutputRule - settings that apply only to outputs with paths matching Glob.
+// Globs without a path separator are matched against the output's file name.
+type OutputRule struct {
+	Glob string `yaml:"glob"`
+
+	// strip a UTF-8 byte-order mark from the input template
+	StripBOM bool `yaml:"stripBOM,omitempty"`
+	// normalise line endings to 'lf' or 'crlf'
+	LineEndings string `yaml:"lineEndings,omitempty"`
+	// transcode the output to the given character set
+	Charset string `yaml:"charset,omitempty"`
+
+	// fail if the output can't be parsed in the given format ('json', 'yaml',
+	// or 'toml')
+	Validate string `yaml:"validate,omitempty"`
+	// reformat the output in the Validate format (e.g. pretty-printed JSON
+	// with sorted keys)
+	Format bool `yaml:"format,omitempty"`
+
+	// a command to run after the output has been written, only when its
+	// content changed
+	PostExec []string `yaml:"postExec,omitempty,flow"`
+
+	// set the output's owner and/or group, in 'user:group' form
+	Chown string `yaml:"chown,omitempty"`
+	// give the output the same owner and group as the input file
+	InheritOwner bool `yaml:"inheritOwner,omitempty"`
+	// the mode (in octal) for any parent directories created for the output
+	DirMode string `yaml:"dirMode,omitempty"`
+}
+
+// mergeFrom - use this as default, and override with non-zero values from o
+func (r OutputRule) mergeFrom(o OutputRule) OutputRule {
+	if o.StripBOM {
+		r.StripBOM = true
+	}
+	if o.LineEndings != "" {
+		r.LineEndings = o.LineEndings
+	}
+	if o.Charset != "" {
+		r.Charset = o.Charset
+	}
+	if o.Validate != "" {
+		r.Validate = o.Validate
+	}
+	if o.Format {
+		r.Format = true
+	}
+	if len(o.PostExec) > 0 {
+		r.PostExec = o.PostExec
+	}
+	// Chown and InheritOwner are alternatives, so the last one set wins
+	if o.Chown != "" {
+		r.Chown = o.Chown
+		r.InheritOwner = false
+	}
+	if o.InheritOwner {
+		r.Chown = ""
+		r.InheritOwner = true
+	}
+	if o.DirMode != "" {
+		r.DirMode = o.DirMode
+	}
+	return r
+}
+
+// GetDirMode - parses the octal DirMode, returning false if it's not set
+func (r OutputRule) GetDirMode() (os.FileMode, bool, error) {
+	if r.DirMode == "" {
+		return 0, false, nil
+	}
+	m, err := strconv.ParseUint(r.DirMode, 8, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid directory mode %q: %w", r.DirMode, err)
+	}
+	if m&^uint64(os.ModePerm) != 0 {
+		return 0, false, fmt.Errorf("invalid directory mode %q: only permission bits may be set", r.DirMode)
+	}
+	return os.FileMode(m), true, nil
+}
+
+// ParseChown - splits a 'user:group' ownership spec into its user and group
+// parts, either (but not both) of which may be empty.
+func ParseChown(spec string) (user, group string, err error) {
+	parts := strings.Split(spec, ":")
+	switch {
+	case len(parts) > 2:
+		return "", "", fmt.Errorf("invalid owner %q: must be in 'user:group' form", spec)
+	case len(parts) == 2:
+		user, group = parts[0], parts[1]
+	default:
+		user = parts[0]
+	}
+	if user == "" && group == "" {
+		return "", "", fmt.Errorf("invalid owner %q: user or group must be given", spec)
+	}
+	return user, group, nil
+}
+
+// matches - whether the given output path matches the rule's glob
+func (r OutputRule) matches(outPath string) bool {
+	glob := filepath.ToSlash(r.Glob)
+	outPath = filepath.ToSlash(outPath)
+	if !strings.Contains(glob, "/") {
+		outPath = path.Base(outPath)
+	}
+	ok, _ := path.Match(glob, outPath)
+	return ok
+}
+
+// OutputRuleFor - returns the effective settings for the given output path,
+// starting with the global settings and applying all matching OutputRules in
+// order.
+func (c *Config) OutputRuleFor(outPath string) OutputRule {
+	r := OutputRule{
+		StripBOM:     c.StripBOM,
+		LineEndings:  c.LineEndings,
+		Charset:      c.OutputCharset,
+		Chown:        c.Chown,
+		InheritOwner: c.InheritOwner,
+		DirMode:      c.DirMode,
+	}
+	for _, o := range c.OutputRules {
+		if o.matches(outPath) {
+			r = r.mergeFrom(o)
+		}
+	}
+	return r
+}
+
 // mergeDataSources - use d as defaults, and override with values from o
 func mergeDataSources(d, o map[string]DataSource) map[string]DataSource {
 	for k, v := range o {
@@ -109,14 +318,28 @@ func mergeDataSources(d, o map[string]DataSource) map[string]DataSource {
 type DataSource struct {
 	URL    *url.URL    `yaml:"-"`
 	Header http.Header `yaml:"header,omitempty,flow"`
+
+	// CacheTTL - how long the datasource is cached for, when a cacheDir is
+	// set. Overrides the config's cacheTTL.
+	CacheTTL time.Duration `yaml:"cacheTTL,omitempty"`
+	// CacheSecrets - allow caching datasources which are likely to hold
+	// secrets, like vault or aws+sm
+	CacheSecrets bool `yaml:"cacheSecrets,omitempty"`
+
+	// HTTP client options, for http and https datasources. Options which
+	// aren't set are taken from the config's http options.
+	HTTP HTTPConfig `yaml:"http,omitempty"`
 }
 
 // UnmarshalYAML - satisfy the yaml.Umarshaler interface - URLs aren't
 // well supported, and anyway we need to do some extra parsing
 func (d *DataSource) UnmarshalYAML(value *yaml.Node) error {
 	type raw struct {
-		Header http.Header
-		URL    string
+		Header       http.Header
+		URL          string
+		CacheTTL     time.Duration `yaml:"cacheTTL"`
+		CacheSecrets bool          `yaml:"cacheSecrets"`
+		HTTP         HTTPConfig    `yaml:"http"`
 	}
 	r := raw{}
 	err := value.Decode(&r)
@@ -128,8 +351,11 @@ func (d *DataSource) UnmarshalYAML(value *yaml.Node) error {
 		return fmt.Errorf("could not parse datasource URL %q: %w", r.URL, err)
 	}
 	*d = DataSource{
-		URL:    u,
-		Header: r.Header,
+		URL:          u,
+		Header:       r.Header,
+		CacheTTL:     r.CacheTTL,
+		CacheSecrets: r.CacheSecrets,
+		HTTP:         r.HTTP,
 	}
 	return nil
 }
@@ -138,12 +364,18 @@ func (d *DataSource) UnmarshalYAML(value *yaml.Node) error {
 // well supported, and anyway we need to do some extra parsing
 func (d DataSource) MarshalYAML() (interface{}, error) {
 	type raw struct {
-		Header http.Header
-		URL    string
+		Header       http.Header `yaml:",omitempty"`
+		URL          string
+		CacheTTL     time.Duration `yaml:"cacheTTL,omitempty"`
+		CacheSecrets bool          `yaml:"cacheSecrets,omitempty"`
+		HTTP         HTTPConfig    `yaml:"http,omitempty"`
 	}
 	r := raw{
-		URL:    d.URL.String(),
-		Header: d.Header,
+		URL:          d.URL.String(),
+		Header:       d.Header,
+		CacheTTL:     d.CacheTTL,
+		CacheSecrets: d.CacheSecrets,
+		HTTP:         d.HTTP,
 	}
 	return r, nil
 }
@@ -160,9 +392,275 @@ func (d DataSource) mergeFrom(o DataSource) DataSource {
 			d.Header[k] = v
 		}
 	}
+	if o.CacheTTL != 0 {
+		d.CacheTTL = o.CacheTTL
+	}
+	if o.CacheSecrets {
+		d.CacheSecrets = true
+	}
+	d.HTTP = d.HTTP.MergeFrom(o.HTTP)
 	return d
 }
 
+// HTTPConfig - HTTP client options for http and https datasources
+type HTTPConfig struct {
+	// Timeout - the time limit for each attempt at a request. Defaults to 5s.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Retries - how many times to retry requests which fail with a connection
+	// error or a 5xx status
+	Retries int `yaml:"retries,omitempty"`
+	// RetryWait - how long to wait before the first retry. The wait doubles
+	// for each retry after that. Defaults to 250ms.
+	RetryWait time.Duration `yaml:"retryWait,omitempty"`
+
+	// CACert - a PEM file of CA certificates to trust, in addition to the
+	// system's
+	CACert string `yaml:"caCert,omitempty"`
+	// ClientCert and ClientKey - PEM files with a certificate and key for
+	// TLS client authentication
+	ClientCert string `yaml:"clientCert,omitempty"`
+	ClientKey  string `yaml:"clientKey,omitempty"`
+	// InsecureSkipVerify - don't verify the server's certificate. Dangerous!
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty"`
+
+	// Proxy - the URL of a proxy to use, instead of the one set in the
+	// environment (by HTTP_PROXY, etc)
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// Method - the request method. Defaults to GET. Only for datasources.
+	Method string `yaml:"method,omitempty"`
+	// Body and BodyFile - the request body, inline or from a file. Only for
+	// datasources.
+	Body     string `yaml:"body,omitempty"`
+	BodyFile string `yaml:"bodyFile,omitempty"`
+	// TemplateBody - render the body as a template, with the datasource's
+	// arguments as .Args, instead of using the first argument as a sub-path
+	TemplateBody bool `yaml:"templateBody,omitempty"`
+
+	// Paginate - follow paginated responses. Only for datasources.
+	Paginate PaginateConfig `yaml:"paginate,omitempty"`
+}
+
+// isRequest - whether any per-request options are set
+func (h HTTPConfig) isRequest() bool {
+	return h.Method != "" || h.Body != "" || h.BodyFile != "" || h.TemplateBody ||
+		h.Paginate != PaginateConfig{}
+}
+
+// Pagination modes
+const (
+	// PaginateLink - follow `Link: <url>; rel="next"` response headers
+	PaginateLink = "link"
+	// PaginateCursor - follow a cursor (or URL) found in each page
+	PaginateCursor = "cursor"
+)
+
+// PaginateConfig - how to read all the pages of a paginated HTTP datasource.
+// The pages are concatenated into a single JSON array.
+type PaginateConfig struct {
+	// Mode - 'link' or 'cursor'. Pagination is disabled when unset.
+	Mode string `yaml:"mode,omitempty"`
+	// NextCursor - a JSONPath to the next page's cursor in each page, for the
+	// 'cursor' mode. There are no more pages when it's missing or empty.
+	NextCursor string `yaml:"nextCursor,omitempty"`
+	// CursorParam - the query parameter to send the cursor in. When unset,
+	// the cursor is the next page's URL.
+	CursorParam string `yaml:"cursorParam,omitempty"`
+	// Items - a JSONPath to the array of results in each page, if the page
+	// isn't an array itself
+	Items string `yaml:"items,omitempty"`
+	// MaxPages - the most pages to read. Defaults to 100.
+	MaxPages int `yaml:"maxPages,omitempty"`
+}
+
+// Validate - check the options are consistent
+func (p PaginateConfig) Validate() error {
+	switch p.Mode {
+	case "":
+		if p != (PaginateConfig{}) {
+			return fmt.Errorf("paginate: mode must be set")
+		}
+	case PaginateLink:
+		if p.NextCursor != "" || p.CursorParam != "" {
+			return fmt.Errorf("paginate: nextCursor and cursorParam can only be used with mode '%s'", PaginateCursor)
+		}
+	case PaginateCursor:
+		if p.NextCursor == "" {
+			return fmt.Errorf("paginate: nextCursor must be set with mode '%s'", PaginateCursor)
+		}
+	default:
+		return fmt.Errorf("paginate: invalid mode %q (must be '%s' or '%s')", p.Mode, PaginateLink, PaginateCursor)
+	}
+	if p.MaxPages < 0 {
+		return fmt.Errorf("paginate: maxPages must not be negative (%d)", p.MaxPages)
+	}
+	return nil
+}
+
+// MergeFrom - use this as default, and override with the options set in o
+func (h HTTPConfig) MergeFrom(o HTTPConfig) HTTPConfig {
+	if o.Timeout != 0 {
+		h.Timeout = o.Timeout
+	}
+	if o.Retries != 0 {
+		h.Retries = o.Retries
+	}
+	if o.RetryWait != 0 {
+		h.RetryWait = o.RetryWait
+	}
+	if o.CACert != "" {
+		h.CACert = o.CACert
+	}
+	if o.ClientCert != "" {
+		h.ClientCert = o.ClientCert
+	}
+	if o.ClientKey != "" {
+		h.ClientKey = o.ClientKey
+	}
+	if o.InsecureSkipVerify {
+		h.InsecureSkipVerify = true
+	}
+	if o.Proxy != "" {
+		h.Proxy = o.Proxy
+	}
+	if o.Method != "" {
+		h.Method = o.Method
+	}
+	// the body can be given only one way
+	if o.Body != "" || o.BodyFile != "" {
+		h.Body = o.Body
+		h.BodyFile = o.BodyFile
+	}
+	if o.TemplateBody {
+		h.TemplateBody = true
+	}
+	if o.Paginate != (PaginateConfig{}) {
+		h.Paginate = o.Paginate
+	}
+	return h
+}
+
+// Validate - check the options are consistent
+func (h HTTPConfig) Validate() error {
+	if (h.ClientCert == "") != (h.ClientKey == "") {
+		return fmt.Errorf("clientCert and clientKey must be set together")
+	}
+	if h.Retries < 0 {
+		return fmt.Errorf("retries must not be negative (%d)", h.Retries)
+	}
+	if h.Timeout < 0 || h.RetryWait < 0 {
+		return fmt.Errorf("timeout and retryWait must not be negative")
+	}
+	if h.Proxy != "" {
+		u, err := url.Parse(h.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q: must be absolute", h.Proxy)
+		}
+	}
+	if h.Body != "" && h.BodyFile != "" {
+		return fmt.Errorf("body and bodyFile can't be set together")
+	}
+	if h.TemplateBody && h.Body == "" && h.BodyFile == "" {
+		return fmt.Errorf("templateBody requires body or bodyFile")
+	}
+	if strings.ContainsAny(h.Method, " \t\r\n") {
+		return fmt.Errorf("invalid method %q", h.Method)
+	}
+	return h.Paginate.Validate()
+}
+
+// Plugin protocols
+const (
+	// PluginProtocolExec - the plugin's command is run once per call, with the
+	// arguments given on the commandline
+	PluginProtocolExec = "exec"
+	// PluginProtocolJSONRPC - the plugin's command is started once, and is
+	// called with JSON-RPC 2.0 requests on its standard input
+	PluginProtocolJSONRPC = "jsonrpc"
+	// PluginProtocolWasm - the plugin is a WebAssembly module, which is run
+	// in-process, and binds each of its exported functions
+	PluginProtocolWasm = "wasm"
+)
+
+// PluginConfig - plugin configuration
+type PluginConfig struct {
+	// Cmd - the path to the plugin's executable (or script)
+	Cmd string `yaml:"cmd"`
+	// Protocol - how gomplate talks to the plugin, one of 'exec', 'jsonrpc',
+	// or 'wasm'. Defaults to 'wasm' for .wasm files, and 'exec' otherwise.
+	Protocol string `yaml:"protocol,omitempty"`
+	// Mounts - host directories that WebAssembly plugins are allowed to
+	// access. Plugins have no filesystem access otherwise.
+	Mounts []PluginMount `yaml:"mounts,omitempty"`
+	// Stdin - when true, the last argument (usually the pipeline value) is
+	// written to the plugin's standard input instead of its commandline
+	Stdin bool `yaml:"stdin,omitempty"`
+	// Output - how to parse the plugin's output, one of 'string' (the
+	// default), 'json', or 'yaml'
+	Output string `yaml:"output,omitempty"`
+	// Cache - when true, results are cached by argument list for the duration
+	// of the run, so repeated calls with the same arguments only call the
+	// plugin once
+	Cache bool `yaml:"cache,omitempty"`
+	// MaxConcurrency - the maximum number of calls to the plugin that can run
+	// at once. Zero means no limit.
+	MaxConcurrency int `yaml:"maxConcurrency,omitempty"`
+}
+
+// PluginMount - a host directory made available to a WebAssembly plugin
+type PluginMount struct {
+	// Host - the directory on the host
+	Host string `yaml:"host"`
+	// Guest - the path the plugin sees the directory at. Defaults to the
+	// host path.
+	Guest    string `yaml:"guest,omitempty"`
+	ReadOnly bool   `yaml:"readOnly,omitempty"`
+}
+
+// GetProtocol - the plugin's protocol, with the default applied
+func (p PluginConfig) GetProtocol() string {
+	switch {
+	case p.Protocol != "":
+		return p.Protocol
+	case strings.EqualFold(filepath.Ext(p.Cmd), ".wasm"):
+		return PluginProtocolWasm
+	default:
+		return PluginProtocolExec
+	}
+}
+
+// UnmarshalYAML - satisfy the yaml.Umarshaler interface - plugins can be
+// given as just the command, for brevity
+func (p *PluginConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = PluginConfig{Cmd: value.Value}
+		return nil
+	}
+
+	type raw PluginConfig
+	r := raw{}
+	err := value.Decode(&r)
+	if err != nil {
+		return err
+	}
+	*p = PluginConfig(r)
+	return nil
+}
+
+// MarshalYAML - satisfy the yaml.Marshaler interface - plugins with only a
+// command are marshaled in the short form
+func (p PluginConfig) MarshalYAML() (interface{}, error) {
+	if p.Protocol == "" && len(p.Mounts) == 0 && !p.Stdin && p.Output == "" &&
+		!p.Cache && p.MaxConcurrency == 0 {
+		return p.Cmd, nil
+	}
+	type raw PluginConfig
+	return raw(p), nil
+}
+
 // MergeFrom - use this Config as the defaults, and override it with any
 // non-zero values from the other Config
 //
@@ -208,12 +706,69 @@ func (c *Config) MergeFrom(o *Config) *Config {
 		c.PostExec = o.PostExec
 		c.OutputFiles = o.OutputFiles
 	}
+	if !isZero(o.ExecOnChange) {
+		c.ExecOnChange = o.ExecOnChange
+	}
+	if !isZero(o.PostExec) {
+		c.PostExec = o.PostExec
+	}
+	if !isZero(o.SuppressEmpty) {
+		c.SuppressEmpty = o.SuppressEmpty
+	}
+	if !isZero(o.Experimental) {
+		c.Experimental = o.Experimental
+	}
+	if o.PluginTimeout != 0 {
+		c.PluginTimeout = o.PluginTimeout
+	}
+	if !isZero(o.CacheDir) {
+		c.CacheDir = o.CacheDir
+	}
+	if o.CacheTTL != 0 {
+		c.CacheTTL = o.CacheTTL
+	}
+	if !isZero(o.Offline) {
+		c.Offline = o.Offline
+	}
+	c.HTTP = c.HTTP.MergeFrom(o.HTTP)
 	if !isZero(o.ExcludeGlob) {
 		c.ExcludeGlob = o.ExcludeGlob
 	}
+	if !isZero(o.CopyGlob) {
+		c.CopyGlob = o.CopyGlob
+	}
+	if !isZero(o.Symlinks) {
+		c.Symlinks = o.Symlinks
+	}
+	if !isZero(o.RewriteSymlinks) {
+		c.RewriteSymlinks = o.RewriteSymlinks
+	}
 	if !isZero(o.OutMode) {
 		c.OutMode = o.OutMode
 	}
+	if !isZero(o.Chown) {
+		c.Chown = o.Chown
+		c.InheritOwner = false
+	}
+	if !isZero(o.InheritOwner) {
+		c.Chown = ""
+		c.InheritOwner = o.InheritOwner
+	}
+	if !isZero(o.DirMode) {
+		c.DirMode = o.DirMode
+	}
+	if !isZero(o.StripBOM) {
+		c.StripBOM = o.StripBOM
+	}
+	if !isZero(o.LineEndings) {
+		c.LineEndings = o.LineEndings
+	}
+	if !isZero(o.OutputCharset) {
+		c.OutputCharset = o.OutputCharset
+	}
+	if len(o.OutputRules) > 0 {
+		c.OutputRules = o.OutputRules
+	}
 	if !isZero(o.LDelim) {
 		c.LDelim = o.LDelim
 	}
@@ -223,17 +778,121 @@ func (c *Config) MergeFrom(o *Config) *Config {
 	if !isZero(o.Templates) {
 		c.Templates = o.Templates
 	}
-	mergeDataSources(c.DataSources, o.DataSources)
-	mergeDataSources(c.Context, o.Context)
+	if len(o.DataSources) > 0 {
+		if c.DataSources == nil {
+			c.DataSources = map[string]DataSource{}
+		}
+		mergeDataSources(c.DataSources, o.DataSources)
+	}
+	if len(o.Context) > 0 {
+		if c.Context == nil {
+			c.Context = map[string]DataSource{}
+		}
+		mergeDataSources(c.Context, o.Context)
+	}
+	if len(o.DataSourceOverrides) > 0 {
+		if c.DataSourceOverrides == nil {
+			c.DataSourceOverrides = map[string]DataSource{}
+		}
+		mergeDataSources(c.DataSourceOverrides, o.DataSourceOverrides)
+	}
 	if len(o.Plugins) > 0 {
+		if c.Plugins == nil {
+			c.Plugins = map[string]PluginConfig{}
+		}
 		for k, v := range o.Plugins {
 			c.Plugins[k] = v
 		}
 	}
+	if len(o.Jobs) > 0 {
+		c.Jobs = o.Jobs
+	}
+	if len(o.Profiles) > 0 {
+		if c.Profiles == nil {
+			c.Profiles = map[string]*Config{}
+		}
+		for k, v := range o.Profiles {
+			if p, ok := c.Profiles[k]; ok && p != nil && v != nil {
+				c.Profiles[k] = p.MergeFrom(v)
+			} else {
+				c.Profiles[k] = v
+			}
+		}
+	}
 
 	return c
 }
 
+// ApplyProfile - overlay the named profile on this config. An empty name is a
+// no-op.
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok || p == nil {
+		names := make([]string, 0, len(c.Profiles))
+		for k := range c.Profiles {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %q: no profiles are defined", name)
+		}
+		return fmt.Errorf("unknown profile %q (must be one of: %s)", name, strings.Join(names, ", "))
+	}
+	c.MergeFrom(p)
+	c.Profile = name
+	return nil
+}
+
+// JobConfig - the config for the i'th job: a copy of this config, without its
+// inputs, outputs, and post-exec options, overlaid with the job's options. The
+// defaults are applied to the returned config.
+func (c *Config) JobConfig(i int) *Config {
+	j := *c
+	j.Input = ""
+	j.InputDir = ""
+	j.InputFiles = nil
+	j.OutputDir = ""
+	j.OutputMap = ""
+	j.OutputFiles = nil
+	j.PostExec = nil
+	j.ExecPipe = false
+	j.ExecOnChange = false
+	j.Jobs = nil
+	j.Profiles = nil
+
+	// merging modifies the maps, which are shared with this config
+	j.DataSources = cloneDataSources(c.DataSources)
+	j.Context = cloneDataSources(c.Context)
+	j.DataSourceOverrides = cloneDataSources(c.DataSourceOverrides)
+	if c.Plugins != nil {
+		j.Plugins = make(map[string]PluginConfig, len(c.Plugins))
+		for k, v := range c.Plugins {
+			j.Plugins[k] = v
+		}
+	}
+
+	if c.Jobs[i] != nil {
+		j.MergeFrom(c.Jobs[i])
+	}
+	j.ApplyDefaults()
+	return &j
+}
+
+func cloneDataSources(in map[string]DataSource) map[string]DataSource {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]DataSource, len(in))
+	for k, v := range in {
+		v.Header = v.Header.Clone()
+		out[k] = v
+	}
+	return out
+}
+
 // ParseDataSourceFlags - sets the DataSources and Context fields from the
 // key=value format flags as provided at the command-line
 func (c *Config) ParseDataSourceFlags(datasources, contexts, headers []string) error {
@@ -281,6 +940,25 @@ func (c *Config) ParseDataSourceFlags(datasources, contexts, headers []string) e
 	return nil
 }
 
+// ParseDataSourceOverrideFlags - sets the DataSourceOverrides field from the
+// alias=URL format flags as provided at the command-line
+func (c *Config) ParseDataSourceOverrideFlags(overrides []string) error {
+	for _, o := range overrides {
+		if !strings.Contains(o, "=") {
+			return fmt.Errorf("invalid datasource override (%s): must be in alias=URL form", o)
+		}
+		k, ds, err := parseDatasourceArg(o)
+		if err != nil {
+			return err
+		}
+		if c.DataSourceOverrides == nil {
+			c.DataSourceOverrides = map[string]DataSource{}
+		}
+		c.DataSourceOverrides[k] = ds
+	}
+	return nil
+}
+
 // ParsePluginFlags - sets the Plugins field from the
 // key=value format flags as provided at the command-line
 func (c *Config) ParsePluginFlags(plugins []string) error {
@@ -290,9 +968,9 @@ func (c *Config) ParsePluginFlags(plugins []string) error {
 			return fmt.Errorf("plugin requires both name and path")
 		}
 		if c.Plugins == nil {
-			c.Plugins = map[string]string{}
+			c.Plugins = map[string]PluginConfig{}
 		}
-		c.Plugins[parts[0]] = parts[1]
+		c.Plugins[parts[0]] = PluginConfig{Cmd: parts[1]}
 	}
 	return nil
 }
@@ -400,9 +1078,254 @@ func (c Config) Validate() (err error) {
 		}
 	}
 
+	if err == nil {
+		err = notTogether([]string{"execPipe", "execOnChange"},
+			c.ExecPipe, c.ExecOnChange)
+	}
+
+	if err == nil {
+		err = mustTogether("offline", "cacheDir",
+			c.Offline, c.CacheDir)
+	}
+
+	if err == nil && c.Offline && !c.cachesAnything() {
+		err = fmt.Errorf("offline requires a positive cacheTTL (or a cacheTTL on a datasource), since nothing is cached without one")
+	}
+
+	if err == nil {
+		err = c.validateHTTP()
+	}
+
+	if err == nil {
+		err = mustTogether("copy", "inputDir",
+			c.CopyGlob, c.InputDir)
+	}
+
+	if err == nil {
+		err = mustTogether("symlinks", "inputDir",
+			c.Symlinks, c.InputDir)
+	}
+
+	if err == nil {
+		switch c.Symlinks {
+		case "", SymlinksFollow, SymlinksPreserve, SymlinksSkip:
+		default:
+			err = fmt.Errorf("invalid symlinks mode %q (must be '%s', '%s', or '%s')",
+				c.Symlinks, SymlinksFollow, SymlinksPreserve, SymlinksSkip)
+		}
+	}
+
+	if err == nil && c.RewriteSymlinks && c.Symlinks != SymlinksPreserve {
+		err = fmt.Errorf("rewriteSymlinks may only be used when symlinks is '%s'", SymlinksPreserve)
+	}
+
+	if err == nil {
+		err = notTogether([]string{"chown", "inheritOwner"},
+			c.Chown, c.InheritOwner)
+	}
+
+	if err == nil {
+		err = c.validatePlugins()
+	}
+
+	if err == nil {
+		for name, p := range c.Profiles {
+			if p != nil && len(p.Profiles) > 0 {
+				err = fmt.Errorf("profile %q must not contain profiles", name)
+				break
+			}
+			if p != nil && len(p.Include) > 0 {
+				err = fmt.Errorf("profile %q must not contain includes", name)
+				break
+			}
+		}
+	}
+
+	if err == nil {
+		err = c.validateOutputRules()
+	}
+
+	if err == nil && len(c.Jobs) > 0 {
+		err = c.validateJobs()
+	}
+
 	return err
 }
 
+// cachesAnything - whether a positive cache TTL is set anywhere - for all
+// datasources, or on a datasource (or its URL) in the config or its jobs
+func (c Config) cachesAnything() bool {
+	configs := append([]*Config{&c}, c.Jobs...)
+	for _, cfg := range configs {
+		if cfg == nil {
+			continue
+		}
+		if cfg.CacheTTL > 0 {
+			return true
+		}
+		for _, sources := range []map[string]DataSource{cfg.DataSources, cfg.Context, cfg.DataSourceOverrides} {
+			for _, ds := range sources {
+				if ds.CacheTTL > 0 {
+					return true
+				}
+				if ds.URL == nil {
+					continue
+				}
+				if v := ds.URL.Query().Get("cacheTTL"); v != "" {
+					ttl, err := time.ParseDuration(v)
+					// invalid TTLs are reported when the datasource is read
+					if err != nil || ttl > 0 {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// validateHTTP - check the HTTP options, and those of each datasource (as
+// merged with the defaults)
+func (c Config) validateHTTP() error {
+	err := c.HTTP.Validate()
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	if c.HTTP.isRequest() {
+		return fmt.Errorf("http: method, body, bodyFile, templateBody, and paginate can only be set on datasources")
+	}
+	for _, sources := range []map[string]DataSource{c.DataSources, c.Context, c.DataSourceOverrides} {
+		aliases := make([]string, 0, len(sources))
+		for alias := range sources {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			err := c.HTTP.MergeFrom(sources[alias].HTTP).Validate()
+			if err != nil {
+				return fmt.Errorf("datasource %q: http: %w", alias, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Config) validateJobs() error {
+	// the top-level inputs and outputs are defaulted to stdin/stdout
+	isStdio := func(files []string) bool {
+		return len(files) == 0 || (len(files) == 1 && files[0] == "-")
+	}
+	if c.Input != "" || c.InputDir != "" || c.OutputMap != "" ||
+		!isStdio(c.InputFiles) || !isStdio(c.OutputFiles) {
+		return fmt.Errorf("inputs and outputs must be set in each job when jobs are defined")
+	}
+	if c.ExecPipe {
+		return fmt.Errorf("execPipe must be set in a job when jobs are defined")
+	}
+	for i, j := range c.Jobs {
+		if j == nil {
+			continue
+		}
+		switch {
+		case len(j.Jobs) > 0:
+			return fmt.Errorf("job %d must not contain jobs", i)
+		case len(j.Profiles) > 0:
+			return fmt.Errorf("job %d must not contain profiles", i)
+		case len(j.Include) > 0:
+			return fmt.Errorf("job %d must not contain includes", i)
+		}
+		if err := c.JobConfig(i).Validate(); err != nil {
+			return fmt.Errorf("job %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c Config) validatePlugins() error {
+	for name, p := range c.Plugins {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("invalid plugin %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (p PluginConfig) validate() error {
+	if p.Cmd == "" {
+		return fmt.Errorf("no command given")
+	}
+	if IsPluginManifest(p.Cmd) && (p.Protocol != "" || len(p.Mounts) > 0 || p.Stdin || p.Output != "") {
+		return fmt.Errorf("protocol, mounts, stdin, and output must be set in the plugin manifest")
+	}
+	if err := validatePluginIO(p.GetProtocol(), p.Stdin, p.Output); err != nil {
+		return err
+	}
+	if p.MaxConcurrency < 0 {
+		return fmt.Errorf("maxConcurrency must not be negative")
+	}
+	switch p.GetProtocol() {
+	case PluginProtocolExec, PluginProtocolJSONRPC:
+		if len(p.Mounts) > 0 {
+			return fmt.Errorf("mounts are only supported for WebAssembly plugins")
+		}
+	case PluginProtocolWasm:
+		for _, m := range p.Mounts {
+			if m.Host == "" {
+				return fmt.Errorf("mount has no host directory")
+			}
+		}
+	default:
+		return fmt.Errorf("invalid protocol %q (must be '%s', '%s', or '%s')",
+			p.Protocol, PluginProtocolExec, PluginProtocolJSONRPC, PluginProtocolWasm)
+	}
+	return nil
+}
+
+func (c Config) validateOutputRules() error {
+	rules := append([]OutputRule{{
+		Glob:        "*",
+		LineEndings: c.LineEndings,
+		Charset:     c.OutputCharset,
+		Chown:       c.Chown,
+		DirMode:     c.DirMode,
+	}}, c.OutputRules...)
+	for _, r := range rules {
+		if _, err := path.Match(filepath.ToSlash(r.Glob), ""); err != nil {
+			return fmt.Errorf("invalid output rule glob %q: %w", r.Glob, err)
+		}
+		if r.LineEndings != "" {
+			if _, err := iohelpers.LineEndings(r.LineEndings); err != nil {
+				return err
+			}
+		}
+		if r.Charset != "" {
+			if _, err := iohelpers.CharsetEncoder(r.Charset); err != nil {
+				return err
+			}
+		}
+		switch r.Validate {
+		case "", "json", "yaml", "toml":
+		default:
+			return fmt.Errorf("invalid output rule for %q: unsupported validation format %q (must be 'json', 'yaml', or 'toml')", r.Glob, r.Validate)
+		}
+		if r.Format && r.Validate == "" {
+			return fmt.Errorf("invalid output rule for %q: 'format' requires 'validate' to be set", r.Glob)
+		}
+		if r.Chown != "" {
+			if _, _, err := ParseChown(r.Chown); err != nil {
+				return err
+			}
+		}
+		if _, _, err := r.GetDirMode(); err != nil {
+			return err
+		}
+		if r.Chown != "" && r.InheritOwner {
+			return fmt.Errorf("invalid output rule for %q: only one of 'chown' and 'inheritOwner' may be set", r.Glob)
+		}
+	}
+	return nil
+}
+
 func notTogether(names []string, values ...interface{}) error {
 	found := ""
 	for i, value := range values {
@@ -513,6 +1436,8 @@ func (c *Config) String() string {
 // or it may be a Windows path (with driver letter and back-slack separators) or
 // UNC, or it may be relative. It also might just be a regular absolute URL...
 // In all cases it returns a correct URL for the value.
+//
+// Any credentials embedded in the URL are registered for redaction.
 func ParseSourceURL(value string) (*url.URL, error) {
 	if value == "-" {
 		value = "stdin://"
@@ -532,6 +1457,7 @@ func ParseSourceURL(value string) (*url.URL, error) {
 	if err != nil {
 		return nil, err
 	}
+	redact.AddURL(srcURL)
 
 	if volName != "" && len(srcURL.Path) >= 3 {
 		if srcURL.Path[0] == '/' && srcURL.Path[2] == ':' {
@@ -37,12 +37,19 @@ datasources:
     url: https://example.com/more.json
     header:
       Authorization: ["Bearer abcd1234"]
+    cacheTTL: 1h
 
 context:
   .:
     url: file:///data.json
 
 pluginTimeout: 2s
+
+plugins:
+  figlet: /usr/local/bin/figlet
+  text:
+    cmd: /usr/local/bin/textplugin
+    protocol: jsonrpc
 `
 	expected = &Config{
 		Input:       "hello world",
@@ -56,6 +63,7 @@ pluginTimeout: 2s
 				Header: map[string][]string{
 					"Authorization": {"Bearer abcd1234"},
 				},
+				CacheTTL: time.Hour,
 			},
 		},
 		Context: map[string]DataSource{
@@ -65,6 +73,10 @@ pluginTimeout: 2s
 		},
 		OutMode:       "644",
 		PluginTimeout: 2 * time.Second,
+		Plugins: map[string]PluginConfig{
+			"figlet": {Cmd: "/usr/local/bin/figlet"},
+			"text":   {Cmd: "/usr/local/bin/textplugin", Protocol: "jsonrpc"},
+		},
 	}
 
 	cf, err = Parse(strings.NewReader(in))
@@ -162,6 +174,333 @@ postExec: [echo]
 execPipe: true
 outputMap: foo
 postExec: [echo]
+`))
+
+	assert.Error(t, validateConfig(`execPipe: true
+execOnChange: true
+postExec: [echo]
+`))
+
+	assert.NoError(t, validateConfig(`execOnChange: true
+postExec: [echo]
+`))
+
+	assert.NoError(t, validateConfig(`lineEndings: crlf
+outputCharset: latin1
+outputRules:
+  - glob: '*.bat'
+    lineEndings: crlf
+    charset: windows-1252
+`))
+
+	assert.Error(t, validateConfig(`lineEndings: cr
+`))
+
+	assert.Error(t, validateConfig(`outputCharset: bogus
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '[*.bat'
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.bat'
+    charset: bogus
+`))
+
+	assert.NoError(t, validateConfig(`outputRules:
+  - glob: '*.json'
+    validate: json
+    format: true
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.xml'
+    validate: xml
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.json'
+    format: true
+`))
+
+	assert.NoError(t, validateConfig(`chown: app:app
+dirMode: '0750'
+outputRules:
+  - glob: '*.key'
+    chown: root
+  - glob: '*.conf'
+    inheritOwner: true
+`))
+
+	assert.Error(t, validateConfig(`chown: app
+inheritOwner: true
+`))
+
+	assert.NoError(t, validateConfig(`inputDir: in
+outputDir: out
+copy: ['*.png']
+`))
+
+	assert.Error(t, validateConfig(`in: foo
+copy: ['*.png']
+`))
+
+	assert.NoError(t, validateConfig(`inputDir: in
+outputDir: out
+symlinks: preserve
+rewriteSymlinks: true
+`))
+
+	assert.Error(t, validateConfig(`inputDir: in
+outputDir: out
+symlinks: dereference
+`))
+
+	assert.NoError(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    protocol: jsonrpc
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    protocol: grpc
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    protocol: jsonrpc
+`))
+
+	assert.NoError(t, validateConfig(`plugins:
+  text:
+    cmd: text.wasm
+    mounts:
+      - host: ./data
+        guest: /data
+        readOnly: true
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    mounts:
+      - host: ./data
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: text.wasm
+    mounts:
+      - guest: /data
+`))
+
+	assert.NoError(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    stdin: true
+    output: json
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    output: xml
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    protocol: jsonrpc
+    stdin: true
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: text.yaml
+    output: json
+`))
+
+	assert.NoError(t, validateConfig(`plugins:
+  text:
+    cmd: text.yaml
+    cache: true
+    maxConcurrency: 2
+`))
+
+	assert.Error(t, validateConfig(`plugins:
+  text:
+    cmd: textplugin
+    maxConcurrency: -1
+`))
+
+	assert.Error(t, validateConfig(`in: foo
+symlinks: skip
+`))
+
+	assert.Error(t, validateConfig(`inputDir: in
+outputDir: out
+symlinks: follow
+rewriteSymlinks: true
+`))
+
+	assert.Error(t, validateConfig(`chown: a:b:c
+`))
+
+	assert.Error(t, validateConfig(`dirMode: '999'
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.conf'
+    chown: ':'
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.conf'
+    dirMode: '4755'
+`))
+
+	assert.Error(t, validateConfig(`outputRules:
+  - glob: '*.conf'
+    chown: app
+    inheritOwner: true
+`))
+
+	assert.Error(t, validateConfig(`offline: true
+`))
+	assert.Error(t, validateConfig(`offline: true
+cacheDir: /tmp/cache
+`))
+	assert.NoError(t, validateConfig(`offline: true
+cacheDir: /tmp/cache
+cacheTTL: 1h
+`))
+	assert.NoError(t, validateConfig(`offline: true
+cacheDir: /tmp/cache
+datasources:
+  api:
+    url: https://example.com/api.json
+    cacheTTL: 10m
+`))
+	assert.NoError(t, validateConfig(`offline: true
+cacheDir: /tmp/cache
+jobs:
+  - in: hi
+    datasources:
+      api:
+        url: https://example.com/api.json?cacheTTL=10m
+`))
+
+	assert.Error(t, validateConfig(`http:
+  retries: -1
+`))
+	assert.Error(t, validateConfig(`http:
+  proxy: proxy:3128
+`))
+	assert.Error(t, validateConfig(`http:
+  clientCert: cert.pem
+`))
+	assert.NoError(t, validateConfig(`http:
+  clientCert: cert.pem
+  clientKey: key.pem
+datasources:
+  api:
+    url: https://example.com
+    http:
+      clientKey: other-key.pem
+`))
+	err := validateConfig(`datasources:
+  api:
+    url: https://example.com
+  other:
+    url: https://example.com
+    http:
+      clientKey: key.pem
+`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `datasource "other": http: clientCert and clientKey must be set together`)
+
+	err = validateConfig(`http:
+  method: POST
+`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "can only be set on datasources")
+	assert.Error(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      body: '{}'
+      bodyFile: body.json
+`))
+	assert.Error(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      templateBody: true
+`))
+	assert.NoError(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      method: POST
+      bodyFile: body.json
+      templateBody: true
+`))
+
+	err = validateConfig(`http:
+  paginate:
+    mode: link
+`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "can only be set on datasources")
+	err = validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      paginate:
+        mode: pages
+`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `paginate: invalid mode "pages"`)
+	assert.Error(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      paginate:
+        maxPages: 5
+`))
+	assert.Error(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      paginate:
+        mode: cursor
+`))
+	assert.Error(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      paginate:
+        mode: link
+        nextCursor: $.next
+`))
+	assert.NoError(t, validateConfig(`datasources:
+  api:
+    url: https://example.com
+    http:
+      paginate:
+        mode: link
+        maxPages: 5
+  list:
+    url: https://example.com
+    http:
+      paginate:
+        mode: cursor
+        nextCursor: $.meta.next
+        cursorParam: after
+        items: $.data
 `))
 }
 
@@ -298,28 +637,340 @@ func TestMergeFrom(t *testing.T) {
 	cfg = &Config{
 		Input:       "hello world",
 		OutputFiles: []string{"-"},
-		Plugins: map[string]string{
-			"sleep": "echo",
+		Plugins: map[string]PluginConfig{
+			"sleep": {Cmd: "echo"},
 		},
 		PluginTimeout: 500 * time.Microsecond,
 	}
 	other = &Config{
 		InputFiles:  []string{"-"},
 		OutputFiles: []string{"-"},
-		Plugins: map[string]string{
-			"sleep": "sleep.sh",
+		Plugins: map[string]PluginConfig{
+			"sleep": {Cmd: "sleep.sh"},
 		},
 	}
 	expected = &Config{
 		Input:       "hello world",
 		OutputFiles: []string{"-"},
-		Plugins: map[string]string{
-			"sleep": "sleep.sh",
+		Plugins: map[string]PluginConfig{
+			"sleep": {Cmd: "sleep.sh"},
 		},
 		PluginTimeout: 500 * time.Microsecond,
 	}
 
 	assert.EqualValues(t, expected, cfg.MergeFrom(other))
+
+	cfg = &Config{
+		Input: "hello world",
+		DataSourceOverrides: map[string]DataSource{
+			"foo": {URL: mustURL("foo.json")},
+		},
+	}
+	other = &Config{
+		DataSourceOverrides: map[string]DataSource{
+			"bar": {URL: mustURL("bar.json")},
+		},
+	}
+	expected = &Config{
+		Input: "hello world",
+		DataSourceOverrides: map[string]DataSource{
+			"foo": {URL: mustURL("foo.json")},
+			"bar": {URL: mustURL("bar.json")},
+		},
+	}
+
+	assert.EqualValues(t, expected, cfg.MergeFrom(other))
+
+	cfg = &Config{
+		Input:   "hello world",
+		Chown:   "app:app",
+		DirMode: "0755",
+	}
+	other = &Config{
+		InheritOwner: true,
+	}
+	expected = &Config{
+		Input:        "hello world",
+		InheritOwner: true,
+		DirMode:      "0755",
+	}
+
+	assert.EqualValues(t, expected, cfg.MergeFrom(other))
+
+	cfg = &Config{
+		Input:         "hello world",
+		PostExec:      []string{"echo"},
+		PluginTimeout: 500 * time.Millisecond,
+	}
+	other = &Config{
+		PostExec:      []string{"cat"},
+		PluginTimeout: 2 * time.Second,
+		SuppressEmpty: true,
+		DataSources: map[string]DataSource{
+			"foo": {URL: mustURL("foo.json")},
+		},
+	}
+	expected = &Config{
+		Input:         "hello world",
+		PostExec:      []string{"cat"},
+		PluginTimeout: 2 * time.Second,
+		SuppressEmpty: true,
+		DataSources: map[string]DataSource{
+			"foo": {URL: mustURL("foo.json")},
+		},
+	}
+
+	assert.EqualValues(t, expected, cfg.MergeFrom(other))
+
+	cfg = &Config{
+		Profiles: map[string]*Config{
+			"prod": {OutputDir: "out/prod", LDelim: "(("},
+		},
+	}
+	other = &Config{
+		Profiles: map[string]*Config{
+			"prod": {OutputDir: "dist"},
+			"test": {Input: "hi"},
+		},
+	}
+	expected = &Config{
+		Profiles: map[string]*Config{
+			"prod": {OutputDir: "dist", LDelim: "(("},
+			"test": {Input: "hi"},
+		},
+	}
+
+	assert.EqualValues(t, expected, cfg.MergeFrom(other))
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Parallel()
+	in := `inputDir: templates
+outputDir: out/dev
+datasources:
+  api:
+    url: https://dev.example.com/api.json
+profiles:
+  prod:
+    outputDir: out/prod
+    datasources:
+      api:
+        url: https://example.com/api.json
+  test:
+    in: hello
+`
+	cfg, err := Parse(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.Validate())
+
+	assert.NoError(t, cfg.ApplyProfile(""))
+	assert.Equal(t, "", cfg.Profile)
+	assert.Equal(t, "out/dev", cfg.OutputDir)
+
+	assert.NoError(t, cfg.ApplyProfile("prod"))
+	assert.Equal(t, "prod", cfg.Profile)
+	assert.Equal(t, "templates", cfg.InputDir)
+	assert.Equal(t, "out/prod", cfg.OutputDir)
+	assert.Equal(t, "https://example.com/api.json", cfg.DataSources["api"].URL.String())
+
+	cfg, err = Parse(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.ApplyProfile("test"))
+	assert.Equal(t, "hello", cfg.Input)
+	assert.Equal(t, "", cfg.InputDir)
+
+	err = cfg.ApplyProfile("staging")
+	assert.EqualError(t, err, `unknown profile "staging" (must be one of: prod, test)`)
+
+	err = (&Config{}).ApplyProfile("prod")
+	assert.EqualError(t, err, `unknown profile "prod": no profiles are defined`)
+
+	assert.Error(t, validateConfig(`profiles:
+  prod:
+    profiles:
+      nested:
+        in: hi
+`))
+
+	assert.Error(t, validateConfig(`profiles:
+  prod:
+    include: [prod.yaml]
+`))
+}
+
+func TestJobConfig(t *testing.T) {
+	t.Parallel()
+	in := `leftDelim: ((
+datasources:
+  api:
+    url: https://example.com/api.json
+    header:
+      Accept: [application/json]
+postExec: [echo, done]
+jobs:
+  - inputDir: site
+    outputDir: public
+  - in: hello
+    outputFiles: [hello.txt]
+    leftDelim: "[["
+    datasources:
+      api:
+        url: https://example.com/api.json
+        header:
+          Authorization: [Bearer foo]
+    postExec: [cat, hello.txt]
+    execOnChange: true
+`
+	cfg, err := Parse(strings.NewReader(in))
+	assert.NoError(t, err)
+	cfg.ApplyDefaults()
+	assert.NoError(t, cfg.Validate())
+
+	j := cfg.JobConfig(0)
+	assert.Equal(t, "site", j.InputDir)
+	assert.Equal(t, "public", j.OutputDir)
+	assert.Empty(t, j.InputFiles)
+	assert.Empty(t, j.OutputFiles)
+	assert.Equal(t, "((", j.LDelim)
+	assert.Equal(t, "}}", j.RDelim)
+	assert.Empty(t, j.PostExec)
+	assert.Empty(t, j.Jobs)
+
+	j = cfg.JobConfig(1)
+	assert.Equal(t, "hello", j.Input)
+	assert.Equal(t, []string{"hello.txt"}, j.OutputFiles)
+	assert.Equal(t, "[[", j.LDelim)
+	assert.Equal(t, []string{"cat", "hello.txt"}, j.PostExec)
+	assert.True(t, j.ExecOnChange)
+	assert.Equal(t, "https://example.com/api.json", j.DataSources["api"].URL.String())
+	assert.Equal(t, "Bearer foo", j.DataSources["api"].Header.Get("Authorization"))
+
+	// the top-level config isn't modified
+	assert.Equal(t, "((", cfg.LDelim)
+	assert.Equal(t, []string{"echo", "done"}, cfg.PostExec)
+	assert.Empty(t, cfg.DataSources["api"].Header.Get("Authorization"))
+
+	assert.Error(t, validateConfig(`inputFiles: [in.txt]
+outputFiles: [out.txt]
+jobs:
+  - in: hi
+`))
+
+	assert.Error(t, validateConfig(`jobs:
+  - in: hi
+    jobs:
+      - in: there
+`))
+
+	assert.Error(t, validateConfig(`jobs:
+  - in: hi
+    include: [other.yaml]
+`))
+
+	err = validateConfig(`jobs:
+  - in: hi
+  - inputDir: in
+    outputFiles: [out.txt]
+`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "job 1: ")
+}
+
+func TestOutputRuleFor(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{}
+	assert.Equal(t, OutputRule{}, cfg.OutputRuleFor("out.txt"))
+
+	cfg = &Config{
+		LineEndings: "lf",
+		OutputRules: []OutputRule{
+			{Glob: "*.bat", LineEndings: "crlf", Charset: "windows-1252"},
+			{Glob: "legacy/*", StripBOM: true, Charset: "latin1"},
+		},
+	}
+	assert.Equal(t, OutputRule{LineEndings: "lf"}, cfg.OutputRuleFor("out.txt"))
+	assert.Equal(t, OutputRule{LineEndings: "crlf", Charset: "windows-1252"},
+		cfg.OutputRuleFor("out/run.bat"))
+	assert.Equal(t, OutputRule{LineEndings: "crlf", Charset: "latin1", StripBOM: true},
+		cfg.OutputRuleFor("legacy/run.bat"))
+	assert.Equal(t, OutputRule{LineEndings: "lf"},
+		cfg.OutputRuleFor("out/legacy/run.txt"))
+
+	cfg = &Config{
+		OutputRules: []OutputRule{
+			{Glob: "*.json", Validate: "json"},
+			{Glob: "out/*.json", Format: true},
+		},
+	}
+	assert.Equal(t, OutputRule{Validate: "json"}, cfg.OutputRuleFor("foo.json"))
+	assert.Equal(t, OutputRule{Validate: "json", Format: true}, cfg.OutputRuleFor("out/foo.json"))
+
+	cfg = &Config{
+		OutputRules: []OutputRule{
+			{Glob: "*.conf", PostExec: []string{"nginx", "-t"}},
+			{Glob: "sites/*.conf", PostExec: []string{"nginx", "-s", "reload"}},
+		},
+	}
+	assert.Equal(t, OutputRule{PostExec: []string{"nginx", "-t"}}, cfg.OutputRuleFor("nginx.conf"))
+	assert.Equal(t, OutputRule{PostExec: []string{"nginx", "-s", "reload"}}, cfg.OutputRuleFor("sites/foo.conf"))
+
+	cfg = &Config{
+		InheritOwner: true,
+		DirMode:      "0755",
+		OutputRules: []OutputRule{
+			{Glob: "*.key", Chown: "root:root", DirMode: "0700"},
+			{Glob: "public/*.key", InheritOwner: true},
+		},
+	}
+	assert.Equal(t, OutputRule{InheritOwner: true, DirMode: "0755"}, cfg.OutputRuleFor("app.conf"))
+	assert.Equal(t, OutputRule{Chown: "root:root", DirMode: "0700"}, cfg.OutputRuleFor("private/app.key"))
+	assert.Equal(t, OutputRule{InheritOwner: true, DirMode: "0700"}, cfg.OutputRuleFor("public/app.key"))
+}
+
+func TestGetDirMode(t *testing.T) {
+	m, o, err := OutputRule{}.GetDirMode()
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0), m)
+	assert.False(t, o)
+
+	m, o, err = OutputRule{DirMode: "0750"}.GetDirMode()
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o750), m)
+	assert.True(t, o)
+
+	m, o, err = OutputRule{DirMode: "700"}.GetDirMode()
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), m)
+	assert.True(t, o)
+
+	_, _, err = OutputRule{DirMode: "foo"}.GetDirMode()
+	assert.Error(t, err)
+
+	_, _, err = OutputRule{DirMode: "1777"}.GetDirMode()
+	assert.Error(t, err)
+}
+
+func TestParseChown(t *testing.T) {
+	testdata := []struct {
+		in, user, group string
+	}{
+		{"app", "app", ""},
+		{"app:", "app", ""},
+		{"app:web", "app", "web"},
+		{":web", "", "web"},
+		{"1000:1000", "1000", "1000"},
+	}
+	for _, d := range testdata {
+		u, g, err := ParseChown(d.in)
+		assert.NoError(t, err)
+		assert.Equal(t, d.user, u)
+		assert.Equal(t, d.group, g)
+	}
+
+	for _, in := range []string{"", ":", "a:b:c"} {
+		_, _, err := ParseChown(in)
+		assert.Error(t, err, in)
+	}
 }
 
 func TestParseDataSourceFlags(t *testing.T) {
@@ -385,6 +1036,38 @@ func TestParseDataSourceFlags(t *testing.T) {
 	}, cfg)
 }
 
+func TestParseDataSourceOverrideFlags(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{}
+	err := cfg.ParseDataSourceOverrideFlags(nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, &Config{}, cfg)
+
+	cfg = &Config{}
+	err = cfg.ParseDataSourceOverrideFlags([]string{"secrets.json"})
+	assert.Error(t, err)
+
+	cfg = &Config{}
+	err = cfg.ParseDataSourceOverrideFlags([]string{
+		"secrets=./fixtures/secrets.json",
+		"token=data:,abcd1234",
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, &Config{
+		DataSourceOverrides: map[string]DataSource{
+			"secrets": {URL: mustURL("./fixtures/secrets.json")},
+			"token":   {URL: mustURL("data:,abcd1234")},
+		},
+	}, cfg)
+}
+
+func TestPluginConfigGetProtocol(t *testing.T) {
+	assert.Equal(t, PluginProtocolExec, PluginConfig{Cmd: "foo"}.GetProtocol())
+	assert.Equal(t, PluginProtocolWasm, PluginConfig{Cmd: "foo.wasm"}.GetProtocol())
+	assert.Equal(t, PluginProtocolWasm, PluginConfig{Cmd: "FOO.WASM"}.GetProtocol())
+	assert.Equal(t, PluginProtocolJSONRPC, PluginConfig{Cmd: "foo", Protocol: "jsonrpc"}.GetProtocol())
+}
+
 func TestParsePluginFlags(t *testing.T) {
 	t.Parallel()
 	cfg := &Config{}
@@ -394,7 +1077,7 @@ func TestParsePluginFlags(t *testing.T) {
 	cfg = &Config{}
 	err = cfg.ParsePluginFlags([]string{"foo=bar"})
 	assert.NoError(t, err)
-	assert.EqualValues(t, &Config{Plugins: map[string]string{"foo": "bar"}}, cfg)
+	assert.EqualValues(t, &Config{Plugins: map[string]PluginConfig{"foo": {Cmd: "bar"}}}, cfg)
 }
 
 func TestConfigString(t *testing.T) {
@@ -476,6 +1159,28 @@ pluginTimeout: 500ms
 `
 
 	assert.Equal(t, expected, c.String())
+
+	c = &Config{
+		Plugins: map[string]PluginConfig{
+			"figlet": {Cmd: "figlet"},
+			"keys":   {Cmd: "keys.sh", Stdin: true, Output: PluginOutputYAML},
+			"text":   {Cmd: "text.yaml", Cache: true, MaxConcurrency: 2},
+		},
+	}
+	expected = `---
+plugins:
+  figlet: figlet
+  keys:
+    cmd: keys.sh
+    stdin: true
+    output: yaml
+  text:
+    cmd: text.yaml
+    cache: true
+    maxConcurrency: 2
+`
+
+	assert.Equal(t, expected, c.String())
 }
 
 func TestApplyDefaults(t *testing.T) {
@@ -756,3 +1461,25 @@ func TestFromContext(t *testing.T) {
 	// assert that the returned config looks like a default one
 	assert.Equal(t, "{{", cfg.LDelim)
 }
+
+func TestHTTPConfigMergeFrom(t *testing.T) {
+	h := HTTPConfig{Timeout: time.Second, Retries: 2, CACert: "ca.pem"}
+	assert.Equal(t, h, h.MergeFrom(HTTPConfig{}))
+	assert.Equal(t, HTTPConfig{
+		Timeout:            time.Second,
+		Retries:            5,
+		CACert:             "ca.pem",
+		InsecureSkipVerify: true,
+		Proxy:              "http://proxy:3128",
+	}, h.MergeFrom(HTTPConfig{Retries: 5, InsecureSkipVerify: true, Proxy: "http://proxy:3128"}))
+
+	cfg := &Config{HTTP: h}
+	cfg.MergeFrom(&Config{HTTP: HTTPConfig{Timeout: time.Minute}})
+	assert.Equal(t, time.Minute, cfg.HTTP.Timeout)
+	assert.Equal(t, 2, cfg.HTTP.Retries)
+
+	// pagination options are replaced together
+	h = HTTPConfig{Paginate: PaginateConfig{Mode: PaginateCursor, NextCursor: ".next", MaxPages: 5}}
+	assert.Equal(t, HTTPConfig{Paginate: PaginateConfig{Mode: PaginateLink}},
+		h.MergeFrom(HTTPConfig{Paginate: PaginateConfig{Mode: PaginateLink}}))
+}
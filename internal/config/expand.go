@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/env"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputPathEnvVar is set to the output's path when running an output rule's
+// postExec command. It isn't set when config files are parsed, so references
+// to it must be escaped (`$${GOMPLATE_OUTPUT_PATH}`) or written without braces.
+const OutputPathEnvVar = "GOMPLATE_OUTPUT_PATH"
+
+var varNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// expandNode - expand environment variable references in all of the node's
+// scalar values (but not keys)
+func expandNode(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return nil
+		}
+		v, err := expandVars(n.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		n.Value = v
+		// unquoted values are re-resolved, so they can expand to non-strings
+		// (like booleans or numbers)
+		if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			n.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := expandNode(n.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, c := range n.Content {
+			if err := expandNode(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandVars - expand references to environment variables in the form
+// `${VAR}` or `${VAR:-default}`, with the same semantics as env.Getenv
+// (including `_FILE` support). `$$` is an escaped `$`, and any other `$` is
+// left as-is.
+func expandVars(s string) (string, error) {
+	out := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			out.WriteByte('$')
+			i++
+		case '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			v, err := expandRef(s[i : end+1])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(v)
+			i = end
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String(), nil
+}
+
+// matchingBrace - the index of the '}' closing the reference starting at i,
+// allowing for nested references in defaults, or -1 if there isn't one
+func matchingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expandRef - expand a single `${...}` reference
+func expandRef(ref string) (string, error) {
+	name, def := ref[2:len(ref)-1], ""
+	hasDef := false
+	if i := strings.Index(name, ":-"); i >= 0 {
+		name, def, hasDef = name[:i], name[i+2:], true
+	}
+	if !varNameRE.MatchString(name) {
+		return "", fmt.Errorf("invalid variable reference %q", ref)
+	}
+	if !hasDef {
+		return env.Getenv(name), nil
+	}
+	def, err := expandVars(def)
+	if err != nil {
+		return "", err
+	}
+	return env.Getenv(name, def), nil
+}
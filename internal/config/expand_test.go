@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandVars(t *testing.T) {
+	t.Setenv("CFG_HOST", "example.com")
+	t.Setenv("CFG_EMPTY", "")
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	assert.NoError(t, os.WriteFile(secret, []byte("hunter2"), 0600))
+	t.Setenv("CFG_SECRET_FILE", secret)
+
+	data := []struct {
+		in, expected string
+	}{
+		{"plain", "plain"},
+		{"https://${CFG_HOST}/v1", "https://example.com/v1"},
+		{"${CFG_UNSET}", ""},
+		{"${CFG_UNSET:-fallback}", "fallback"},
+		{"${CFG_EMPTY:-fallback}", "fallback"},
+		{"${CFG_HOST:-fallback}", "example.com"},
+		{"${CFG_UNSET:-${CFG_HOST}}", "example.com"},
+		{"${CFG_UNSET:-}", ""},
+		{"${CFG_SECRET}", "hunter2"},
+		{"$$", "$"},
+		{"$${CFG_HOST}", "${CFG_HOST}"},
+		{"$CFG_HOST", "$CFG_HOST"},
+		{"{{ $x := 1 }}", "{{ $x := 1 }}"},
+		{"cost: 5$", "cost: 5$"},
+		{"$${GOMPLATE_OUTPUT_PATH}", "${GOMPLATE_OUTPUT_PATH}"},
+	}
+	for _, d := range data {
+		out, err := expandVars(d.in)
+		assert.NoError(t, err, d.in)
+		assert.Equal(t, d.expected, out, d.in)
+	}
+
+	_, err := expandVars("${CFG_HOST")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unterminated variable reference")
+
+	_, err = expandVars("${}")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid variable reference "${}"`)
+
+	_, err = expandVars("${1FOO}")
+	assert.Error(t, err)
+}
+
+func TestParseExpandsVars(t *testing.T) {
+	t.Setenv("CFG_HOST", "example.com")
+	t.Setenv("CFG_OUT", "dist")
+	t.Setenv("CFG_PIPE", "true")
+	t.Setenv("CFG_TIMEOUT", "2s")
+
+	in := `in: 'price: $$5'
+outputDir: ${CFG_OUT}
+inputDir: ${CFG_IN:-templates}
+execOnChange: ${CFG_PIPE}
+pluginTimeout: ${CFG_TIMEOUT}
+datasources:
+  api:
+    url: https://${CFG_HOST}/v1
+    header:
+      Host: ["${CFG_HOST}"]
+`
+	cfg, err := Parse(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.Equal(t, "price: $5", cfg.Input)
+	assert.Equal(t, "dist", cfg.OutputDir)
+	assert.Equal(t, "templates", cfg.InputDir)
+	assert.True(t, cfg.ExecOnChange)
+	assert.Equal(t, 2*time.Second, cfg.PluginTimeout)
+	assert.Equal(t, "https://example.com/v1", cfg.DataSources["api"].URL.String())
+	assert.Equal(t, "example.com", cfg.DataSources["api"].Header.Get("Host"))
+
+	_, err = Parse(strings.NewReader("in: hello\noutputDir: ${CFG_OUT\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2: unterminated variable reference")
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Argument types for functions declared in plugin manifests
+const (
+	PluginArgString = "string"
+	PluginArgNumber = "number"
+	PluginArgBool   = "bool"
+	PluginArgMap    = "map"
+	PluginArgList   = "list"
+	PluginArgAny    = "any"
+)
+
+// Output formats for plugins
+const (
+	PluginOutputString = "string"
+	PluginOutputJSON   = "json"
+	PluginOutputYAML   = "yaml"
+)
+
+// PluginManifest - describes a plugin which provides a namespace of functions,
+// in the same way as gomplate's built-in namespaces
+type PluginManifest struct {
+	// Namespace - the name the functions are grouped under. Defaults to the
+	// plugin's name.
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Cmd - the plugin's executable (or script, or WebAssembly module).
+	// Relative paths are relative to the manifest.
+	Cmd      string        `yaml:"cmd"`
+	Protocol string        `yaml:"protocol,omitempty"`
+	Mounts   []PluginMount `yaml:"mounts,omitempty"`
+
+	Functions map[string]PluginFunction `yaml:"functions"`
+}
+
+// PluginFunction - a function declared in a plugin manifest
+type PluginFunction struct {
+	// Args - the type of each argument, one of 'string', 'number', 'bool',
+	// 'map', 'list', or 'any'
+	Args []string `yaml:"args,omitempty,flow"`
+	// Timeout - overrides the plugin timeout for this function
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Stdin - when true, the last argument (usually the pipeline value) is
+	// written to the plugin's standard input instead of its commandline
+	Stdin bool `yaml:"stdin,omitempty"`
+	// Output - how to parse the plugin's output, one of 'string' (the
+	// default), 'json', or 'yaml'
+	Output string `yaml:"output,omitempty"`
+}
+
+// IsPluginManifest - whether the plugin command is a manifest (a YAML file)
+// rather than an executable
+func IsPluginManifest(cmd string) bool {
+	switch strings.ToLower(filepath.Ext(cmd)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// ParsePluginManifest - parse a plugin manifest
+func ParsePluginManifest(in io.Reader) (*PluginManifest, error) {
+	out := &PluginManifest{}
+	dec := yaml.NewDecoder(in)
+	err := dec.Decode(out)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to parse plugin manifest: %w", err)
+	}
+	return out, nil
+}
+
+// Plugin - the configuration for the manifest's plugin
+func (m PluginManifest) Plugin() PluginConfig {
+	return PluginConfig{Cmd: m.Cmd, Protocol: m.Protocol, Mounts: m.Mounts}
+}
+
+var identifierRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate - check the manifest for errors
+func (m PluginManifest) Validate() error {
+	if m.Namespace != "" && !identifierRE.MatchString(m.Namespace) {
+		return fmt.Errorf("invalid namespace %q in plugin manifest", m.Namespace)
+	}
+	err := m.Plugin().validate()
+	if err != nil {
+		return fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+	if len(m.Functions) == 0 {
+		return fmt.Errorf("plugin manifest declares no functions")
+	}
+
+	protocol := m.Plugin().GetProtocol()
+
+	// sorted for consistent errors
+	names := make([]string, 0, len(m.Functions))
+	for name := range m.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := m.Functions[name]
+		if !identifierRE.MatchString(name) {
+			return fmt.Errorf("invalid function name %q in plugin manifest", name)
+		}
+		for _, a := range f.Args {
+			switch a {
+			case PluginArgString, PluginArgNumber, PluginArgBool, PluginArgMap, PluginArgList, PluginArgAny:
+			default:
+				return fmt.Errorf("function %s: invalid argument type %q", name, a)
+			}
+		}
+		if err := validatePluginIO(protocol, f.Stdin, f.Output); err != nil {
+			return fmt.Errorf("function %s: %w", name, err)
+		}
+		if f.Stdin && len(f.Args) == 0 {
+			return fmt.Errorf("function %s: stdin requires at least one argument", name)
+		}
+	}
+	return nil
+}
+
+// validatePluginIO - check the stdin and output options, which only apply to
+// the exec protocol
+func validatePluginIO(protocol string, stdin bool, output string) error {
+	switch output {
+	case "", PluginOutputString, PluginOutputJSON, PluginOutputYAML:
+	default:
+		return fmt.Errorf("invalid output format %q (must be '%s', '%s', or '%s')",
+			output, PluginOutputString, PluginOutputJSON, PluginOutputYAML)
+	}
+	// other protocols have typed arguments and results
+	if protocol != PluginProtocolExec && (stdin || output != "") {
+		return fmt.Errorf("stdin and output are only supported with the '%s' protocol", PluginProtocolExec)
+	}
+	return nil
+}
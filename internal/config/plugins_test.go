@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPluginManifest(t *testing.T) {
+	assert.True(t, IsPluginManifest("foo.yaml"))
+	assert.True(t, IsPluginManifest("/plugins/foo.YML"))
+	assert.False(t, IsPluginManifest("foo"))
+	assert.False(t, IsPluginManifest("foo.sh"))
+	assert.False(t, IsPluginManifest("foo.wasm"))
+}
+
+func TestParsePluginManifest(t *testing.T) {
+	in := `namespace: text
+cmd: ./text.sh
+functions:
+  upper:
+    args: [string]
+  parse:
+    args: [string, any]
+    timeout: 2s
+    stdin: true
+    output: json
+`
+	expected := &PluginManifest{
+		Namespace: "text",
+		Cmd:       "./text.sh",
+		Functions: map[string]PluginFunction{
+			"upper": {Args: []string{"string"}},
+			"parse": {
+				Args:    []string{"string", "any"},
+				Timeout: 2 * time.Second,
+				Stdin:   true,
+				Output:  PluginOutputJSON,
+			},
+		},
+	}
+	m, err := ParsePluginManifest(strings.NewReader(in))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, m)
+	assert.NoError(t, m.Validate())
+
+	_, err = ParsePluginManifest(strings.NewReader("functions: [foo]"))
+	assert.Error(t, err)
+}
+
+func TestPluginManifestValidate(t *testing.T) {
+	fns := map[string]PluginFunction{"foo": {}}
+	data := []struct {
+		m   PluginManifest
+		err string
+	}{
+		{PluginManifest{Cmd: "foo", Functions: fns}, ""},
+		{PluginManifest{Namespace: "my-ns", Cmd: "foo", Functions: fns}, `invalid namespace "my-ns"`},
+		{PluginManifest{Functions: fns}, "no command given"},
+		{PluginManifest{Cmd: "foo", Protocol: "bogus", Functions: fns}, `invalid protocol "bogus"`},
+		{PluginManifest{Cmd: "foo"}, "declares no functions"},
+		{PluginManifest{Cmd: "foo", Functions: map[string]PluginFunction{"a.b": {}}}, `invalid function name "a.b"`},
+		{PluginManifest{Cmd: "foo", Functions: map[string]PluginFunction{"foo": {Args: []string{"int"}}}}, `function foo: invalid argument type "int"`},
+		{PluginManifest{Cmd: "foo", Functions: map[string]PluginFunction{"foo": {Output: "xml"}}}, `function foo: invalid output format "xml"`},
+		{PluginManifest{Cmd: "foo", Functions: map[string]PluginFunction{"foo": {Stdin: true}}}, "function foo: stdin requires at least one argument"},
+		{PluginManifest{Cmd: "foo", Protocol: PluginProtocolJSONRPC, Functions: map[string]PluginFunction{"foo": {Output: "json"}}}, "only supported with the 'exec' protocol"},
+		{PluginManifest{Cmd: "foo.wasm", Functions: map[string]PluginFunction{"foo": {Args: []string{"any"}, Stdin: true}}}, "only supported with the 'exec' protocol"},
+	}
+	for _, d := range data {
+		err := d.m.Validate()
+		if d.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), d.err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaID - the URL the config file's JSON Schema is published at
+const SchemaID = "https://docs.gomplate.ca/schemas/config.json"
+
+// schema - a (subset of a) JSON Schema (draft-07)
+type schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	OneOf                []*schema          `json:"oneOf,omitempty"`
+
+	Definitions map[string]*schema `json:"definitions,omitempty"`
+}
+
+// schemaEnums - the allowed values of string options, by type and key
+var schemaEnums = map[string][]string{
+	"Config.symlinks":        {SymlinksFollow, SymlinksPreserve, SymlinksSkip},
+	"Config.lineEndings":     {"lf", "crlf"},
+	"OutputRule.lineEndings": {"lf", "crlf"},
+	"OutputRule.validate":    {"json", "yaml", "toml"},
+	"PluginConfig.protocol":  {PluginProtocolExec, PluginProtocolJSONRPC, PluginProtocolWasm},
+	"PluginConfig.output":    {PluginOutputString, PluginOutputJSON, PluginOutputYAML},
+	"PaginateConfig.mode":    {PaginateLink, PaginateCursor},
+}
+
+var (
+	configType     = reflect.TypeOf(Config{})
+	dataSourceType = reflect.TypeOf(DataSource{})
+	pluginType     = reflect.TypeOf(PluginConfig{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	headerType     = reflect.TypeOf(http.Header{})
+)
+
+// configSchema - the schema for the config file, generated from the Config
+// type
+var configSchema = newConfigSchema()
+
+func newConfigSchema() *schema {
+	defs := map[string]*schema{}
+	root := objectSchema(configType, defs)
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.ID = SchemaID
+	root.Title = "gomplate config file"
+	root.Description = "Configuration for gomplate. See https://docs.gomplate.ca/config/"
+	root.Definitions = defs
+	return root
+}
+
+// Schema - the JSON Schema for the config file
+func Schema() ([]byte, error) {
+	b, err := json.MarshalIndent(configSchema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// typeSchema - the schema for values of the given type. Named struct types
+// are added to defs, and referenced.
+func typeSchema(t reflect.Type, defs map[string]*schema) *schema {
+	switch t {
+	case configType:
+		return &schema{Ref: "#"}
+	case durationType:
+		return &schema{Type: "string", Description: "a duration, like '5s' or '1m30s'"}
+	case headerType:
+		return &schema{Type: "object", AdditionalProperties: &schema{
+			Type: "array", Items: &schema{Type: "string"},
+		}}
+	case dataSourceType:
+		// the URL is parsed separately
+		ds := objectSchema(t, defs)
+		ds.Properties["url"] = &schema{Type: "string"}
+		defs[t.Name()] = ds
+		return &schema{Ref: "#/definitions/" + t.Name()}
+	case pluginType:
+		// plugins can be given as just a command
+		defs[t.Name()] = &schema{OneOf: []*schema{
+			{Type: "string"},
+			objectSchema(t, defs),
+		}}
+		return &schema{Ref: "#/definitions/" + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs)
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &schema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = objectSchema(t, defs)
+		}
+		return &schema{Ref: "#/definitions/" + t.Name()}
+	}
+	return &schema{}
+}
+
+// objectSchema - the schema for the struct's YAML fields
+func objectSchema(t reflect.Type, defs map[string]*schema) *schema {
+	s := &schema{
+		Type:                 "object",
+		Properties:           map[string]*schema{},
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		p := typeSchema(f.Type, defs)
+		if enum, ok := schemaEnums[t.Name()+"."+name]; ok {
+			p.Enum = enum
+		}
+		s.Properties[name] = p
+	}
+	return s
+}
+
+// yamlName - the field's key in YAML, or "" if it's not encoded
+func yamlName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return ""
+	}
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return strings.ToLower(f.Name)
+	}
+	return tag
+}
+
+// UnknownKey - a key in a config file which isn't a known option
+type UnknownKey struct {
+	Line int
+	// Path - the (dotted) path to the key's parent, if not at the top level
+	Path string
+	Key  string
+	// Suggestion - a known key which may have been intended, if any
+	Suggestion string
+}
+
+func (k UnknownKey) String() string {
+	s := fmt.Sprintf("line %d: unknown key %q", k.Line, k.Key)
+	if k.Path != "" {
+		s += " in " + k.Path
+	}
+	if k.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %q?)", k.Suggestion)
+	}
+	return s
+}
+
+// UnknownKeysError - returned by Parse when the config file has unknown keys.
+// The config is otherwise parsed as normal.
+type UnknownKeysError struct {
+	Keys []UnknownKey
+}
+
+func (e *UnknownKeysError) Error() string {
+	keys := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		keys[i] = k.String()
+	}
+	return "unknown keys in config: " + strings.Join(keys, "; ")
+}
+
+// unknownKeys - find keys in the node which aren't allowed by the schema
+func unknownKeys(n *yaml.Node) []UnknownKey {
+	keys := []UnknownKey{}
+	configSchema.walk(n, "", func(k UnknownKey) {
+		keys = append(keys, k)
+	})
+	return keys
+}
+
+// resolve - follow the schema's reference, if it has one
+func (s *schema) resolve() *schema {
+	switch {
+	case s.Ref == "#":
+		return configSchema
+	case strings.HasPrefix(s.Ref, "#/definitions/"):
+		return configSchema.Definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	return s
+}
+
+func (s *schema) walk(n *yaml.Node, path string, unknown func(UnknownKey)) {
+	s = s.resolve()
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			s.walk(c, path, unknown)
+		}
+		return
+	}
+
+	// the first alternative of the right type
+	for _, alt := range s.OneOf {
+		alt = alt.resolve()
+		if (alt.Type == "object") == (n.Kind == yaml.MappingNode) {
+			s = alt
+			break
+		}
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			// merge keys are resolved by the YAML parser
+			if k.Value == "<<" {
+				continue
+			}
+			childPath := k.Value
+			if path != "" {
+				childPath = path + "." + k.Value
+			}
+			if p, ok := s.Properties[k.Value]; ok {
+				p.walk(v, childPath, unknown)
+				continue
+			}
+			switch ap := s.AdditionalProperties.(type) {
+			case *schema:
+				ap.walk(v, childPath, unknown)
+			case bool:
+				if !ap {
+					unknown(UnknownKey{Line: k.Line, Path: path, Key: k.Value, Suggestion: s.suggest(k.Value)})
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		if s.Items != nil {
+			for i, c := range n.Content {
+				s.Items.walk(c, fmt.Sprintf("%s[%d]", path, i), unknown)
+			}
+		}
+	}
+}
+
+// suggest - a known key which differs only by case, if there is one
+func (s *schema) suggest(key string) string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.EqualFold(name, key) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema(t *testing.T) {
+	b, err := Schema()
+	assert.NoError(t, err)
+
+	s := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(b, &s))
+	assert.Equal(t, SchemaID, s["$id"])
+
+	props := s["properties"].(map[string]interface{})
+	assert.Contains(t, props, "inputDir")
+	assert.Contains(t, props, "datasources")
+	assert.Contains(t, props, "plugins")
+	assert.NotContains(t, props, "Stdin")
+	assert.NotContains(t, props, "profile")
+	assert.Equal(t, map[string]interface{}{"type": "boolean"}, props["execPipe"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#"},
+		props["jobs"].(map[string]interface{})["items"])
+	assert.Equal(t, []interface{}{"follow", "preserve", "skip"},
+		props["symlinks"].(map[string]interface{})["enum"])
+
+	defs := s["definitions"].(map[string]interface{})
+	assert.Contains(t, defs, "DataSource")
+	assert.Contains(t, defs, "OutputRule")
+	assert.Contains(t, defs, "PluginMount")
+	assert.Len(t, defs["PluginConfig"].(map[string]interface{})["oneOf"], 2)
+}
+
+// the published schema must be regenerated when the config changes
+func TestPublishedSchema(t *testing.T) {
+	b, err := Schema()
+	assert.NoError(t, err)
+
+	published, err := os.ReadFile(filepath.Join("..", "..", "docs", "static", "schemas", "config.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(b), string(published), "run 'make docs/static/schemas/config.json' to update")
+}
+
+func TestParseUnknownKeys(t *testing.T) {
+	in := `in: hello
+outputdir: out
+datasources:
+  api:
+    url: https://example.com
+    headers:
+      Accept: [application/json]
+plugins:
+  short: echo
+  long:
+    cmd: echo
+    timeout: 1s
+outputRules:
+  - glob: '*.json'
+    vaildate: json
+profiles:
+  prod:
+    bogus: true
+defaults: &defaults
+  leftDelim: '(('
+`
+	cfg, err := Parse(strings.NewReader(in))
+	assert.Equal(t, "hello", cfg.Input)
+
+	var uk *UnknownKeysError
+	assert.True(t, errors.As(err, &uk))
+	assert.Equal(t, []UnknownKey{
+		{Line: 2, Key: "outputdir", Suggestion: "outputDir"},
+		{Line: 6, Path: "datasources.api", Key: "headers"},
+		{Line: 12, Path: "plugins.long", Key: "timeout"},
+		{Line: 15, Path: "outputRules[0]", Key: "vaildate"},
+		{Line: 18, Path: "profiles.prod", Key: "bogus"},
+		{Line: 19, Key: "defaults"},
+	}, uk.Keys)
+	assert.Contains(t, err.Error(), `line 2: unknown key "outputdir" (did you mean "outputDir"?)`)
+	assert.Contains(t, err.Error(), `line 6: unknown key "headers" in datasources.api`)
+
+	in = `base: &base
+  leftDelim: '(('
+jobs:
+  - <<: *base
+    in: hello
+`
+	_, err = Parse(strings.NewReader(in))
+	assert.True(t, errors.As(err, &uk))
+	assert.Equal(t, []UnknownKey{{Line: 1, Key: "base"}}, uk.Keys)
+}
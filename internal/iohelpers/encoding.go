@@ -0,0 +1,116 @@
+package iohelpers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// StripBOM removes a leading UTF-8 byte-order mark from b, if present.
+func StripBOM(b []byte) []byte {
+	return bytes.TrimPrefix(b, utf8BOM)
+}
+
+// LineEndings returns a transformer that normalises all line endings (LF or
+// CRLF) to the given style, which must be "lf" or "crlf".
+func LineEndings(style string) (transform.Transformer, error) {
+	switch strings.ToLower(style) {
+	case "lf":
+		return &eolNormalizer{eol: []byte{'\n'}}, nil
+	case "crlf":
+		return &eolNormalizer{eol: []byte{'\r', '\n'}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported line ending style %q (must be 'lf' or 'crlf')", style)
+	}
+}
+
+type eolNormalizer struct {
+	transform.NopResetter
+	eol []byte
+}
+
+// Transform - implements transform.Transformer
+func (t *eolNormalizer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		c := src[nSrc]
+		n := 1
+		switch {
+		case c == '\r' && nSrc+1 == len(src) && !atEOF:
+			// need to see the next byte to know if this is a CRLF
+			return nDst, nSrc, transform.ErrShortSrc
+		case c == '\r' && nSrc+1 < len(src) && src[nSrc+1] == '\n':
+			n = 2
+			fallthrough
+		case c == '\n':
+			if nDst+len(t.eol) > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], t.eol)
+		default:
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = c
+			nDst++
+		}
+		nSrc += n
+	}
+	return nDst, nSrc, nil
+}
+
+// CharsetEncoder returns a transformer that encodes UTF-8 input into the named
+// character set. Names are looked up in the IANA registry, so aliases like
+// "latin1" are supported.
+func CharsetEncoder(name string) (transform.Transformer, error) {
+	enc, err := ianaindex.IANA.Encoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %q: %w", name, err)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported charset %q", name)
+	}
+	return enc.NewEncoder(), nil
+}
+
+// TransformWriter returns an io.WriteCloser which passes all written data
+// through t before writing to w. Close must be called to flush any buffered
+// data - it also closes w, if it's an io.Closer.
+func TransformWriter(w io.Writer, t transform.Transformer) io.WriteCloser {
+	return &transformWriter{
+		Writer: transform.NewWriter(w, t),
+		w:      w,
+	}
+}
+
+type transformWriter struct {
+	*transform.Writer
+	w io.Writer
+}
+
+var (
+	_ io.WriteCloser = (*transformWriter)(nil)
+	_ ChangeReporter = (*transformWriter)(nil)
+)
+
+// Close - implements io.Closer
+func (t *transformWriter) Close() error {
+	err := t.Writer.Close()
+	if err != nil {
+		return err
+	}
+	if c, ok := t.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Changed - implements ChangeReporter
+func (t *transformWriter) Changed() bool {
+	return Changed(t.w)
+}
@@ -0,0 +1,86 @@
+package iohelpers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/transform"
+)
+
+func TestStripBOM(t *testing.T) {
+	assert.Equal(t, []byte("hello"), StripBOM([]byte("\xEF\xBB\xBFhello")))
+	assert.Equal(t, []byte("hello"), StripBOM([]byte("hello")))
+	assert.Equal(t, []byte{}, StripBOM([]byte{}))
+}
+
+func TestLineEndings(t *testing.T) {
+	_, err := LineEndings("cr")
+	assert.Error(t, err)
+
+	testdata := []struct {
+		style, in, expected string
+	}{
+		{"lf", "a\r\nb\nc\r\n", "a\nb\nc\n"},
+		{"LF", "a\rb", "a\rb"},
+		{"crlf", "a\r\nb\nc\n", "a\r\nb\r\nc\r\n"},
+		{"crlf", "no newline", "no newline"},
+		{"crlf", "trailing\r", "trailing\r"},
+	}
+	for _, d := range testdata {
+		tr, err := LineEndings(d.style)
+		require.NoError(t, err)
+		out, _, err := transform.String(tr, d.in)
+		assert.NoError(t, err)
+		assert.Equal(t, d.expected, out)
+	}
+}
+
+func TestCharsetEncoder(t *testing.T) {
+	_, err := CharsetEncoder("bogus")
+	assert.Error(t, err)
+
+	tr, err := CharsetEncoder("latin1")
+	require.NoError(t, err)
+	out, _, err := transform.String(tr, "café")
+	assert.NoError(t, err)
+	assert.Equal(t, "caf\xe9", out)
+
+	tr, err = CharsetEncoder("UTF-16LE")
+	require.NoError(t, err)
+	out, _, err = transform.String(tr, "hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "h\x00i\x00", out)
+}
+
+func TestTransformWriter(t *testing.T) {
+	tr, err := LineEndings("crlf")
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	wc := &bufCloser{Buffer: buf}
+	w := TransformWriter(wc, tr)
+
+	// CRLF split across writes must not be doubled up
+	_, err = w.Write([]byte("foo\r"))
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("\nbar\n"))
+	assert.NoError(t, err)
+	assert.False(t, wc.closed)
+
+	err = w.Close()
+	assert.NoError(t, err)
+	assert.True(t, wc.closed)
+	assert.Equal(t, "foo\r\nbar\r\n", buf.String())
+}
+
+type bufCloser struct {
+	*bytes.Buffer
+	closed bool
+}
+
+func (b *bufCloser) Close() error {
+	b.closed = true
+	return nil
+}
@@ -64,6 +64,11 @@ func (f *emptySkipper) Close() error {
 	return nil
 }
 
+// Changed - implements ChangeReporter
+func (f *emptySkipper) Changed() bool {
+	return f.w != nil && Changed(f.w)
+}
+
 func allWhitespace(p []byte) bool {
 	for _, b := range p {
 		if b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\v' {
@@ -89,8 +94,30 @@ var (
 	_ io.WriteCloser = (*NopCloser)(nil)
 	_ io.WriteCloser = (*emptySkipper)(nil)
 	_ io.WriteCloser = (*sameSkipper)(nil)
+
+	_ ChangeReporter = (*emptySkipper)(nil)
+	_ ChangeReporter = (*sameSkipper)(nil)
+	_ ChangeReporter = (*lazyWriteCloser)(nil)
+	_ ChangeReporter = (*processingWriter)(nil)
 )
 
+// ChangeReporter is implemented by writers that may skip writing to their
+// underlying output, and can report whether they actually wrote anything.
+type ChangeReporter interface {
+	// Changed reports whether the underlying output was (re)written. Only
+	// reliable after the writer has been closed.
+	Changed() bool
+}
+
+// Changed reports whether w wrote to its underlying output. Writers that don't
+// implement ChangeReporter are assumed to have always written.
+func Changed(w io.Writer) bool {
+	if c, ok := w.(ChangeReporter); ok {
+		return c.Changed()
+	}
+	return true
+}
+
 type sameSkipper struct {
 	open func() (io.WriteCloser, error)
 
@@ -171,6 +198,12 @@ func (f *sameSkipper) Close() error {
 	return nil
 }
 
+// Changed - implements ChangeReporter. The output is only opened once a
+// difference has been found, so this is true when a rewrite happened.
+func (f *sameSkipper) Changed() bool {
+	return f.w != nil && Changed(f.w)
+}
+
 // LazyWriteCloser provides an interface to a WriteCloser that will open on the
 // first access. The wrapped io.WriteCloser must be provided by 'open'.
 func LazyWriteCloser(open func() (io.WriteCloser, error)) io.WriteCloser {
@@ -212,3 +245,63 @@ func (l *lazyWriteCloser) Write(p []byte) (n int, err error) {
 	}
 	return w.Write(p)
 }
+
+// Changed - implements ChangeReporter
+func (l *lazyWriteCloser) Changed() bool {
+	return l.w != nil && Changed(l.w)
+}
+
+// ProcessingWriter creates an io.WriteCloser that buffers everything written to
+// it until it's closed. On close, the buffered data is passed through process,
+// and the result is written to the io.Writer provided by 'open'. If process
+// returns an error, 'open' is never called, so nothing is written.
+func ProcessingWriter(open func() (io.Writer, error), process func([]byte) ([]byte, error)) io.WriteCloser {
+	return &processingWriter{
+		buf:     &bytes.Buffer{},
+		open:    open,
+		process: process,
+	}
+}
+
+type processingWriter struct {
+	buf     *bytes.Buffer
+	open    func() (io.Writer, error)
+	process func([]byte) ([]byte, error)
+
+	w io.Writer
+}
+
+var _ io.WriteCloser = (*processingWriter)(nil)
+
+func (p *processingWriter) Write(b []byte) (n int, err error) {
+	return p.buf.Write(b)
+}
+
+// Close - implements io.Closer
+func (p *processingWriter) Close() error {
+	out, err := p.process(p.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	w, err := p.open()
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		return errors.New("nil writer returned by open")
+	}
+	p.w = w
+	_, err = w.Write(out)
+	if c, ok := w.(io.Closer); ok {
+		cerr := c.Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Changed - implements ChangeReporter
+func (p *processingWriter) Changed() bool {
+	return p.w != nil && Changed(p.w)
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/transform"
 )
 
 func TestAllWhitespace(t *testing.T) {
@@ -51,6 +52,7 @@ func TestEmptySkipper(t *testing.T) {
 		assert.Equal(t, len(d.in), n)
 		err = f.Close()
 		assert.NoError(t, err)
+		assert.Equal(t, !d.empty, Changed(f))
 		if d.empty {
 			assert.Nil(t, f.w)
 			assert.False(t, opened)
@@ -108,6 +110,7 @@ func TestSameSkipper(t *testing.T) {
 			assert.Equal(t, len(d.in), n)
 			err = f.Close()
 			assert.NoError(t, err)
+			assert.Equal(t, !d.same, Changed(f))
 			if d.same {
 				assert.Nil(t, f.w)
 				assert.False(t, opened)
@@ -133,12 +136,14 @@ func TestLazyWriteCloser(t *testing.T) {
 	assert.False(t, opened)
 	assert.Nil(t, l.w)
 	assert.False(t, w.closed)
+	assert.False(t, Changed(l))
 
 	p := []byte("hello world")
 	n, err := l.Write(p)
 	assert.NoError(t, err)
 	assert.True(t, opened)
 	assert.Equal(t, 11, n)
+	assert.True(t, Changed(l))
 
 	err = l.Close()
 	assert.NoError(t, err)
@@ -158,3 +163,58 @@ func TestLazyWriteCloser(t *testing.T) {
 	err = l.Close()
 	assert.Error(t, err)
 }
+
+func TestProcessingWriter(t *testing.T) {
+	w := newBufferCloser(&bytes.Buffer{})
+	opened := false
+	p := ProcessingWriter(func() (io.Writer, error) {
+		opened = true
+		return w, nil
+	}, func(b []byte) ([]byte, error) {
+		return bytes.ToUpper(b), nil
+	})
+
+	_, err := p.Write([]byte("hello "))
+	assert.NoError(t, err)
+	_, err = p.Write([]byte("world"))
+	assert.NoError(t, err)
+	assert.False(t, opened)
+
+	err = p.Close()
+	assert.NoError(t, err)
+	assert.True(t, opened)
+	assert.True(t, w.closed)
+	assert.Equal(t, "HELLO WORLD", w.String())
+	assert.True(t, Changed(p))
+
+	// processing errors prevent the writer from being opened
+	opened = false
+	p = ProcessingWriter(func() (io.Writer, error) {
+		opened = true
+		return w, nil
+	}, func(b []byte) ([]byte, error) {
+		return nil, fmt.Errorf("invalid")
+	})
+	_, err = p.Write([]byte("hello"))
+	assert.NoError(t, err)
+	err = p.Close()
+	assert.EqualError(t, err, "invalid")
+	assert.False(t, opened)
+	assert.False(t, Changed(p))
+}
+
+func TestChanged(t *testing.T) {
+	// writers that can't report changes are assumed to have changed
+	assert.True(t, Changed(&bytes.Buffer{}))
+
+	// nested reporters are consulted
+	r := bytes.NewBufferString("foo")
+	s := SameSkipper(r, func() (io.WriteCloser, error) {
+		return newBufferCloser(&bytes.Buffer{}), nil
+	})
+	tw := TransformWriter(s, transform.Nop)
+	_, err := tw.Write([]byte("foo"))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	assert.False(t, Changed(tw))
+}
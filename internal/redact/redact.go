@@ -0,0 +1,192 @@
+// Package redact keeps track of secret values - such as those read from
+// secret-bearing datasources, or credentials embedded in URLs - so that they
+// can be masked before being logged or returned in errors.
+package redact
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mask is the string that secret values are replaced with
+const Mask = "****"
+
+// minLength is the shortest value that will be tracked - masking very short
+// values would mangle too much unrelated text to be useful
+const minLength = 4
+
+var (
+	mu       sync.RWMutex
+	secrets  = map[string]struct{}{}
+	replacer *strings.Replacer
+)
+
+// Add registers the given values as secrets, to be masked by String, Error,
+// and Writer. Values shorter than 4 characters are ignored.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, v := range values {
+		if len(v) < minLength {
+			continue
+		}
+		add(v)
+
+		// secrets may also appear in escaped form, in JSON logs or in
+		// errors quoted with %q
+		if b, err := json.Marshal(v); err == nil {
+			add(string(b[1 : len(b)-1]))
+		}
+		q := strconv.Quote(v)
+		add(q[1 : len(q)-1])
+	}
+}
+
+func add(v string) {
+	if _, ok := secrets[v]; !ok {
+		secrets[v] = struct{}{}
+		replacer = nil
+	}
+}
+
+// AddJSON registers all string values found in the given JSON document as
+// secrets. Object keys are not considered secret. Invalid JSON is ignored.
+func AddJSON(b []byte) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return
+	}
+	addValues(v)
+}
+
+func addValues(v interface{}) {
+	switch v := v.(type) {
+	case string:
+		Add(v)
+	case map[string]interface{}:
+		for _, e := range v {
+			addValues(e)
+		}
+	case []interface{}:
+		for _, e := range v {
+			addValues(e)
+		}
+	}
+}
+
+// AddURL registers the credentials embedded in the URL, if any, as secrets.
+// When no password is present the username is assumed to be a token (as in
+// https://token@github.com/...), except for ssh URLs where it's usually just
+// a login name.
+func AddURL(u *url.URL) {
+	if u == nil || u.User == nil {
+		return
+	}
+	if p, ok := u.User.Password(); ok {
+		Add(p)
+		return
+	}
+	if !strings.Contains(u.Scheme, "ssh") {
+		Add(u.User.Username())
+	}
+}
+
+// String returns s with all registered secrets replaced by Mask.
+func String(s string) string {
+	r := getReplacer()
+	if r == nil {
+		return s
+	}
+	return r.Replace(s)
+}
+
+func getReplacer() *strings.Replacer {
+	mu.RLock()
+	r := replacer
+	n := len(secrets)
+	mu.RUnlock()
+	if r != nil || n == 0 {
+		return r
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if replacer == nil {
+		values := make([]string, 0, len(secrets))
+		for v := range secrets {
+			values = append(values, v)
+		}
+		// longer values first, so that secrets containing other secrets are
+		// masked entirely
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+		pairs := make([]string, 0, 2*len(values))
+		for _, v := range values {
+			pairs = append(pairs, v, Mask)
+		}
+		replacer = strings.NewReplacer(pairs...)
+	}
+	return replacer
+}
+
+// Error wraps err so that its message has all registered secrets masked. The
+// original error is still available with errors.Unwrap, errors.Is, and
+// errors.As. A nil error is returned as nil.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+	return &redactedError{err}
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return String(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// Writer returns an io.Writer which masks all registered secrets before
+// writing to w. Secrets split across multiple writes won't be masked, so this
+// is only suitable for writers like loggers, that write whole messages at
+// once. A nil writer is returned as-is.
+func Writer(w io.Writer) io.Writer {
+	if w == nil {
+		return nil
+	}
+	return &writer{w}
+}
+
+type writer struct {
+	w io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	r := getReplacer()
+	if r == nil {
+		return w.w.Write(p)
+	}
+	_, err := io.WriteString(w.w, r.Replace(string(p)))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
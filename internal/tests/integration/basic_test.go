@@ -166,6 +166,26 @@ func TestBasic_PostRunExecPipe(t *testing.T) {
 	assertSuccess(t, o, e, err, "HELLO WORLD")
 }
 
+func TestBasic_ExecOnChange(t *testing.T) {
+	tmpDir := setupBasicTest(t)
+	out := tmpDir.Join("out")
+	o, e, err := cmd(t, "-i", `{{print "hello world"}}`,
+		"-o", out, "--exec-on-change",
+		"--", "cat", out).run()
+	assertSuccess(t, o, e, err, "hello world")
+
+	// the output is unchanged, so the command isn't run
+	o, e, err = cmd(t, "-i", `{{print "hello world"}}`,
+		"-o", out, "--exec-on-change",
+		"--", "cat", out).run()
+	assertSuccess(t, o, e, err, "")
+
+	o, e, err = cmd(t, "-i", `{{print "goodbye world"}}`,
+		"-o", out, "--exec-on-change",
+		"--", "cat", out).run()
+	assertSuccess(t, o, e, err, "goodbye world")
+}
+
 func TestBasic_EmptyOutputSuppression(t *testing.T) {
 	tmpDir := setupBasicTest(t)
 	out := tmpDir.Join("out")
@@ -256,3 +276,23 @@ func TestBasic_AppliesChmodBeforeWrite(t *testing.T) {
 	assert.NilError(t, err)
 	assert.Equal(t, "hi\n", string(content))
 }
+
+func TestBasic_NormalisesLineEndingsAndCharset(t *testing.T) {
+	tmpDir := fs.NewDir(t, "gomplate-inttests",
+		fs.WithFile("in.tmpl", "\xEF\xBB\xBFcafé\r\n{{ \"ok\" }}\r\n"))
+	t.Cleanup(tmpDir.Remove)
+
+	o, e, err := cmd(t, "-f", tmpDir.Join("in.tmpl"), "--strip-bom", "--line-endings", "lf").run()
+	assertSuccess(t, o, e, err, "café\nok\n")
+
+	o, e, err = cmd(t, "-f", tmpDir.Join("in.tmpl"), "-o", tmpDir.Join("out.txt"),
+		"--strip-bom", "--line-endings", "crlf", "--output-charset", "latin1").run()
+	assertSuccess(t, o, e, err, "")
+
+	out, err := ioutil.ReadFile(tmpDir.Join("out.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "caf\xe9\r\nok\r\n", string(out))
+
+	_, _, err = cmd(t, "-i", "hi", "--line-endings", "cr").run()
+	assert.ErrorContains(t, err, "unsupported line ending style")
+}
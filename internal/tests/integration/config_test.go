@@ -1,8 +1,13 @@
 package integration
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -219,7 +224,7 @@ func TestConfig_ConfigOverridesEnvSuppressEmpty(t *testing.T) {
   {{- print "\t  \n\n\r\n\t\t     \v\n" -}}
 
   {{ print "   " -}}
-out: ./missing
+outputFiles: [./missing]
 suppressEmpty: true
 `)
 
@@ -230,3 +235,312 @@ suppressEmpty: true
 	_, err = os.Stat(tmpDir.Join("missing"))
 	assert.Equal(t, true, os.IsNotExist(err))
 }
+
+func TestConfig_OutputRulesValidateAndFormat(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+	writeFile(tmpDir, "indir/good.json", `{"b": {{ 1 }}, "a": true}`)
+	writeFile(tmpDir, "indir/good.yaml", `foo: {{ "bar" }}`)
+	writeConfig(tmpDir, `inputDir: indir/
+outputDir: outdir/
+outputRules:
+  - glob: '*.json'
+    validate: json
+    format: true
+  - glob: '*.yaml'
+    validate: yaml
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err := ioutil.ReadFile(tmpDir.Join("outdir", "good.json"))
+	assert.NilError(t, err)
+	assert.Equal(t, "{\n  \"a\": true,\n  \"b\": 1\n}\n", string(b))
+
+	b, err = ioutil.ReadFile(tmpDir.Join("outdir", "good.yaml"))
+	assert.NilError(t, err)
+	assert.Equal(t, "foo: bar", string(b))
+
+	writeFile(tmpDir, "indir/bad.yaml", "foo: [{{ \"bar\" }}\n")
+	_, _, err = cmd(t).withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "output outdir/bad.yaml is not valid yaml")
+
+	_, err = os.Stat(tmpDir.Join("outdir", "bad.yaml"))
+	assert.Assert(t, os.IsNotExist(err))
+}
+
+func TestConfig_OutputRulesPostExec(t *testing.T) {
+	if isWindows {
+		t.Skip()
+	}
+
+	tmpDir := setupConfigTest(t)
+	writeFile(tmpDir, "indir/a.conf", `a={{ "1" }}`)
+	writeFile(tmpDir, "indir/b.txt", `b`)
+	writeConfig(tmpDir, `inputDir: indir/
+outputDir: outdir/
+outputRules:
+  - glob: '*.conf'
+    postExec: [sh, -c, 'echo "changed $GOMPLATE_OUTPUT_PATH" >> hook.log']
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err := ioutil.ReadFile(tmpDir.Join("hook.log"))
+	assert.NilError(t, err)
+	assert.Equal(t, "changed outdir/a.conf\n", string(b))
+
+	// unchanged outputs don't trigger the hook
+	o, e, err = cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err = ioutil.ReadFile(tmpDir.Join("hook.log"))
+	assert.NilError(t, err)
+	assert.Equal(t, "changed outdir/a.conf\n", string(b))
+
+	writeFile(tmpDir, "indir/a.conf", `a={{ "2" }}`)
+	o, e, err = cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err = ioutil.ReadFile(tmpDir.Join("hook.log"))
+	assert.NilError(t, err)
+	assert.Equal(t, "changed outdir/a.conf\nchanged outdir/a.conf\n", string(b))
+
+	// a failing hook fails the run
+	writeFile(tmpDir, "indir/a.conf", `a={{ "3" }}`)
+	writeConfig(tmpDir, `inputDir: indir/
+outputDir: outdir/
+outputRules:
+  - glob: '*.conf'
+    postExec: ["false"]
+`)
+	_, _, err = cmd(t).withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "post-exec command for output outdir/a.conf failed")
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+	writeConfig(tmpDir, `in: '{{ .env.name }} {{ (ds "api").host }}'
+context:
+  env:
+    url: env.json
+datasources:
+  api:
+    url: dev-api.json
+profiles:
+  prod:
+    datasources:
+      api:
+        url: prod-api.json
+  quiet:
+    in: shh
+`)
+	writeFile(tmpDir, "env.json", `{"name": "gomplate"}`)
+	writeFile(tmpDir, "dev-api.json", `{"host": "dev.example.com"}`)
+	writeFile(tmpDir, "prod-api.json", `{"host": "example.com"}`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "gomplate dev.example.com")
+
+	o, e, err = cmd(t, "--profile", "prod").withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "gomplate example.com")
+
+	o, e, err = cmd(t).withDir(tmpDir.Path()).
+		withEnv("GOMPLATE_PROFILE", "quiet").run()
+	assertSuccess(t, o, e, err, "shh")
+
+	// the flag takes precedence over the environment
+	o, e, err = cmd(t, "--profile", "prod").withDir(tmpDir.Path()).
+		withEnv("GOMPLATE_PROFILE", "quiet").run()
+	assertSuccess(t, o, e, err, "gomplate example.com")
+
+	// flags take precedence over the profile
+	o, e, err = cmd(t, "--profile", "quiet", "-i", "loud").withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "loud")
+
+	_, e, err = cmd(t, "--profile", "prod", "--verbose").withDir(tmpDir.Path()).run()
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(e, `"profile":"prod"`), e)
+
+	_, _, err = cmd(t, "--profile", "staging").withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, `unknown profile "staging" (must be one of: prod, quiet)`)
+}
+
+func TestConfig_MultipleFilesAndIncludes(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/org/base.yaml":
+			fmt.Fprintln(w, "include: [delims.yaml]\ncontext:\n  org:\n    url: "+tmpDir.Join("org.json"))
+		case "/org/delims.yaml":
+			fmt.Fprintln(w, "leftDelim: '(('\nrightDelim: '))'")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	writeFile(tmpDir, "org.json", `{"name": "Acme"}`)
+	assert.NilError(t, os.Mkdir(tmpDir.Join("conf"), 0755))
+	writeFile(tmpDir, "conf/repo.yaml", "include: ["+srv.URL+"/org/base.yaml]\nin: '((.org.name)) ((.repo.name))'\n")
+	writeFile(tmpDir, "conf/local.yaml", "include: [vars.yaml]\n")
+	writeFile(tmpDir, "conf/vars.yaml", "context:\n  repo:\n    url: "+tmpDir.Join("repo.json")+"\n")
+	writeFile(tmpDir, "repo.json", `{"name": "widgets"}`)
+
+	o, e, err := cmd(t, "--config", "conf/repo.yaml", "--config", "conf/local.yaml").
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "Acme widgets")
+
+	writeFile(tmpDir, "conf/vars.yaml", "include: [local.yaml]\n")
+	_, _, err = cmd(t, "--config", "conf/repo.yaml", "--config", "conf/local.yaml").
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "config file include cycle detected")
+
+	writeFile(tmpDir, "conf/local.yaml", "include: ["+srv.URL+"/org/missing.yaml]\n")
+	_, _, err = cmd(t, "--config", "conf/local.yaml").
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestConfig_Jobs(t *testing.T) {
+	if isWindows {
+		t.Skip()
+	}
+
+	tmpDir := setupConfigTest(t)
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"name": "widgets"}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	writeFile(tmpDir, "indir/index.html", `<h1>{{ (ds "api").name }}</h1>`)
+	writeConfig(tmpDir, `datasources:
+  api:
+    url: `+srv.URL+`/api
+postExec: [sh, -c, 'echo all >> hook.log']
+jobs:
+  - inputDir: indir/
+    outputDir: outdir/
+    postExec: [sh, -c, 'echo site >> hook.log']
+  - in: 'name=(( (ds "api").name ))'
+    outputFiles: [app.conf]
+    leftDelim: '(('
+    rightDelim: '))'
+    postExec: [sh, -c, 'echo conf >> hook.log']
+    execOnChange: true
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err := ioutil.ReadFile(tmpDir.Join("outdir/index.html"))
+	assert.NilError(t, err)
+	assert.Equal(t, "<h1>widgets</h1>", string(b))
+	b, err = ioutil.ReadFile(tmpDir.Join("app.conf"))
+	assert.NilError(t, err)
+	assert.Equal(t, "name=widgets", string(b))
+
+	// the datasource is shared between the jobs
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	b, err = ioutil.ReadFile(tmpDir.Join("hook.log"))
+	assert.NilError(t, err)
+	assert.Equal(t, "site\nconf\nall\n", string(b))
+
+	// the second job's outputs are unchanged, so its hook isn't run
+	o, e, err = cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err = ioutil.ReadFile(tmpDir.Join("hook.log"))
+	assert.NilError(t, err)
+	assert.Equal(t, "site\nconf\nall\nsite\nall\n", string(b))
+
+	_, _, err = cmd(t, "-i", "hi").withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "inputs and outputs must be set in each job when jobs are defined")
+}
+
+func TestConfig_EnvVarExpansion(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+	writeFile(tmpDir, "api.json", `{"name": "widgets"}`)
+	writeConfig(tmpDir, `in: '{{ (ds "api").name }} cost $$5'
+outputFiles: ['${OUT_FILE:-out.txt}']
+datasources:
+  api:
+    url: ${API_PATH}
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).
+		withEnv("API_PATH", "api.json").run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err := ioutil.ReadFile(tmpDir.Join("out.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "widgets cost $5", string(b))
+}
+
+func TestConfig_ShowAndValidate(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+	writeConfig(tmpDir, `inputFiles: [in.tmpl]
+outputFiles: [out.txt]
+datasources:
+  api:
+    url: https://example.com/api.json
+`)
+
+	o, e, err := cmd(t, "config", "show", "--left-delim", "((").
+		withDir(tmpDir.Path()).
+		withEnv("GOMPLATE_RIGHT_DELIM", "))").run()
+	assert.NilError(t, err, e)
+	assert.Assert(t, strings.Contains(o, "inputFiles: [in.tmpl] # file\n"), o)
+	assert.Assert(t, strings.Contains(o, "leftDelim: (( # flag\n"), o)
+	assert.Assert(t, strings.Contains(o, "rightDelim: )) # env\n"), o)
+	assert.Assert(t, strings.Contains(o, "  api: # file\n"), o)
+	assert.Assert(t, strings.Contains(o, "pluginTimeout: 5s # default\n"), o)
+
+	o, e, err = cmd(t, "config", "validate").withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "config is valid\n")
+
+	writeConfig(tmpDir, `inputFiles: [in.tmpl]
+outputFiles: [out.txt]
+datasources:
+  api:
+    url: bogus://example.com/api.json
+plugins:
+  missing: ./no-such-plugin.sh
+`)
+	_, _, err = cmd(t, "config", "validate").withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "found 2 problem(s) with the config")
+	assert.ErrorContains(t, err, `datasource "api" has unsupported URL scheme "bogus"`)
+	assert.ErrorContains(t, err, `plugin "missing"`)
+}
+
+func TestConfig_UnknownKeys(t *testing.T) {
+	tmpDir := setupConfigTest(t)
+	writeConfig(tmpDir, `in: hello
+outputfiles: [out.txt]
+`)
+
+	_, _, err := cmd(t).withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, `line 2: unknown key "outputfiles" (did you mean "outputFiles"?)`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).
+		withEnv("GOMPLATE_STRICT_CONFIG", "false").run()
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", o)
+	assert.Assert(t, strings.Contains(e, `unknown key \"outputfiles\"`), e)
+
+	o, e, err = cmd(t, "--strict-config=false").withDir(tmpDir.Path()).run()
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", o)
+	assert.Assert(t, strings.Contains(e, `unknown key \"outputfiles\"`), e)
+
+	o, e, err = cmd(t, "config", "schema").run()
+	assert.NilError(t, err, e)
+	assert.Assert(t, strings.Contains(o, `"$id": "https://docs.gomplate.ca/schemas/config.json"`), o)
+}
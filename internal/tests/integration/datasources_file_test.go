@@ -3,6 +3,8 @@ package integration
 import (
 	"testing"
 
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	"gotest.tools/v3/fs"
 )
 
@@ -150,3 +152,34 @@ func TestDatasourcess_File(t *testing.T) {
   "QUX": "single quotes ignore $variables"
 }`)
 }
+
+func TestDatasources_FileOverride(t *testing.T) {
+	tmpDir := setupDatasourcesFileTest(t)
+
+	o, e, err := cmd(t,
+		"-d", "secrets=vault:///secret/foo",
+		"--datasource-override", "secrets="+tmpDir.Join("config.json"),
+		"-i", `{{ (ds "secrets").foo.bar }}`).run()
+	assertSuccess(t, o, e, err, "baz")
+
+	o, e, err = cmd(t,
+		"-d", "secrets=vault:///secret/foo?type=application/yaml",
+		"--datasource-override", "secrets=data:,foo:%20bar",
+		"-i", `{{ (ds "secrets").foo }}`).run()
+	assertSuccess(t, o, e, err, "bar")
+
+	// aliases defined by the template can be overridden
+	o, e, err = cmd(t,
+		"--datasource-override", "secrets="+tmpDir.Join("config.json"),
+		"-i", `{{ defineDatasource "secrets" "vault:///secret/foo" }}{{ (ds "secrets").foo.bar }}`).run()
+	assertSuccess(t, o, e, err, "baz")
+
+	// unused overrides are reported, but don't fail the run
+	o, e, err = cmd(t,
+		"-d", "secrets=vault:///secret/foo",
+		"--datasource-override", "secret="+tmpDir.Join("config.json"),
+		"-i", `hello`).run()
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", o)
+	assert.Assert(t, cmp.Contains(e, "unused alias(es): secret"))
+}
@@ -1,9 +1,17 @@
 package integration
 
 import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"gotest.tools/v3/assert"
 )
 
 func setupDatasourcesHTTPTest(t *testing.T) *httptest.Server {
@@ -15,6 +23,9 @@ func setupDatasourcesHTTPTest(t *testing.T) *httptest.Server {
 	mux.HandleFunc("/actually.json", typeHandler("", `{"value": "json"}`))
 	mux.HandleFunc("/bogus.csv", typeHandler("text/plain", `{"value": "json"}`))
 	mux.HandleFunc("/list", typeHandler("application/array+json", `[1, 2, 3, 4, 5]`))
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
 
 	srv := httptest.NewServer(mux)
 	t.Cleanup(srv.Close)
@@ -78,3 +89,174 @@ func TestDatasources_HTTP_AppendQueryAfterSubPaths(t *testing.T) {
 		"-i", "{{ (ds `foo` `bogus.csv`).value }}").run()
 	assertSuccess(t, o, e, err, "json")
 }
+
+func TestDatasources_HTTP_RedactsURLCredentials(t *testing.T) {
+	srv := setupDatasourcesHTTPTest(t)
+	u := strings.Replace(srv.URL, "http://", "http://user:httppassw0rd@", 1)
+
+	_, e, err := cmd(t, "--verbose",
+		"-d", "foo="+u+"/error",
+		"-i", "{{ ds `foo` }}").run()
+	assert.ErrorContains(t, err, "http://user:****@")
+	assert.Assert(t, !strings.Contains(err.Error(), "httppassw0rd"))
+	assert.Assert(t, strings.Contains(e, "user:****@"))
+	assert.Assert(t, !strings.Contains(e, "httppassw0rd"))
+}
+
+func TestDatasources_HTTP_Cache(t *testing.T) {
+	n := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/count", func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"n": %d, "query": %q}`, n, r.URL.RawQuery)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cacheDir := t.TempDir()
+	tmpl := "{{ (ds `foo`).n }} {{ (ds `foo`).query }}"
+
+	o, e, err := cmd(t, "--cache-dir", cacheDir,
+		"-d", "foo="+srv.URL+"/count?a=b&cacheTTL=1h",
+		"-i", tmpl).run()
+	assertSuccess(t, o, e, err, "1 a=b")
+
+	o, e, err = cmd(t, "--cache-dir", cacheDir,
+		"-d", "foo="+srv.URL+"/count?a=b&cacheTTL=1h",
+		"-i", tmpl).run()
+	assertSuccess(t, o, e, err, "1 a=b")
+
+	// without a TTL, the datasource isn't cached
+	o, e, err = cmd(t, "--cache-dir", cacheDir,
+		"-d", "foo="+srv.URL+"/count",
+		"-i", tmpl).run()
+	assertSuccess(t, o, e, err, "2 ")
+
+	o, e, err = cmd(t, "--cache-dir", cacheDir, "--cache-ttl", "1m",
+		"-d", "foo="+srv.URL+"/count",
+		"-i", tmpl).run()
+	assertSuccess(t, o, e, err, "3 ")
+
+	srv.Close()
+
+	o, e, err = cmd(t, "--cache-dir", cacheDir, "--offline",
+		"-d", "foo="+srv.URL+"/count?a=b&cacheTTL=1h",
+		"-i", tmpl).run()
+	assertSuccess(t, o, e, err, "1 a=b")
+
+	_, _, err = cmd(t, "--cache-dir", cacheDir, "--offline", "--cache-ttl", "1h",
+		"-d", "foo="+srv.URL+"/other",
+		"-i", tmpl).run()
+	assert.ErrorContains(t, err, "can't read datasource foo offline: it isn't cached")
+
+	// nothing could have been cached without a TTL
+	_, _, err = cmd(t, "--cache-dir", cacheDir, "--offline",
+		"-d", "foo="+srv.URL+"/other",
+		"-i", tmpl).run()
+	assert.ErrorContains(t, err, "offline requires a positive cacheTTL")
+
+	_, _, err = cmd(t, "--offline", "-i", "hi").run()
+	assert.ErrorContains(t, err, "offline")
+}
+
+func TestDatasources_HTTP_ClientConfig(t *testing.T) {
+	calls := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"calls": %d}`, calls)
+	}))
+	// don't log failed handshakes
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tmpDir := setupConfigTest(t)
+	writeFile(tmpDir, "ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})))
+	writeConfig(tmpDir, `in: '{{ (ds "api").calls }}'
+http:
+  retries: 2
+  retryWait: 1ms
+datasources:
+  api:
+    url: `+srv.URL+`
+    http:
+      caCert: ca.pem
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "2")
+
+	// the server's certificate isn't trusted without the CA
+	_, _, err = cmd(t, "-d", "api="+srv.URL, "-i", `{{ (ds "api").calls }}`).run()
+	assert.ErrorContains(t, err, "certificate")
+
+	o, e, err = cmd(t, "-d", "api="+srv.URL+"?httpInsecureSkipVerify=true", "-i", `{{ (ds "api").calls }}`).run()
+	assertSuccess(t, o, e, err, "3")
+}
+
+func TestDatasources_HTTP_Post(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct{ Query string }{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data": {"query": %q}}`, req.Query)
+	}))
+	defer srv.Close()
+
+	tmpDir := setupConfigTest(t)
+	writeFile(tmpDir, "query.json", `{"query": {{ index .Args 0 | json }}}`)
+	writeConfig(tmpDir, `in: '{{ (ds "gql" "query { services { name } }").data.query }}'
+datasources:
+  gql:
+    url: `+srv.URL+`/graphql
+    header:
+      Content-Type: [application/json]
+    http:
+      method: POST
+      bodyFile: query.json
+      templateBody: true
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "query { services { name } }")
+}
+
+func TestDatasources_HTTP_Paginate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</repos?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"name": "one"}, {"name": "two"}]`)
+		case "2":
+			w.Header().Set("Link", `</repos?page=3>; rel="next", </repos>; rel="first"`)
+			fmt.Fprint(w, `[{"name": "three"}]`)
+		default:
+			fmt.Fprint(w, `[{"name": "four"}]`)
+		}
+	}))
+	defer srv.Close()
+
+	tmpDir := setupConfigTest(t)
+	writeConfig(tmpDir, `in: '{{ range (ds "repos") }}{{ .name }} {{ end }}'
+datasources:
+  repos:
+    url: `+srv.URL+`/repos
+    http:
+      paginate:
+        mode: link
+`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "one two three four ")
+}
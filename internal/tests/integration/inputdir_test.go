@@ -115,6 +115,50 @@ func TestInputDir_InputDirWithModeOverride(t *testing.T) {
 	}
 }
 
+func TestInputDir_CopyGlobs(t *testing.T) {
+	tmpDir := setupInputDirTest(t)
+	tmpDir = fs.NewDir(t, "gomplate-inttests",
+		fs.FromDir(tmpDir.Path()),
+		fs.WithDir("in",
+			fs.WithFile("logo.png", "\x89PNG\r\n\x1a\n\x00{{", fs.WithMode(0600)),
+			fs.WithDir("static",
+				fs.WithFile("page.html", "<p>{{ .Site.Title }}</p>", fs.WithMode(0644)),
+			),
+		),
+	)
+	t.Cleanup(tmpDir.Remove)
+
+	o, e, err := cmd(t,
+		"--input-dir", tmpDir.Join("in"),
+		"--output-dir", tmpDir.Join("out"),
+		"--copy", "*.png",
+		"--copy", "static/**",
+		"--chmod", "0640",
+		"--line-endings", "crlf",
+		"-d", "config="+tmpDir.Join("config.yml"),
+	).run()
+	assertSuccess(t, o, e, err, "")
+
+	testdata := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{tmpDir.Join("out", "eins.txt"), "eins", 0640},
+		{tmpDir.Join("out", "logo.png"), "\x89PNG\r\n\x1a\n\x00{{", 0600},
+		{tmpDir.Join("out", "static", "page.html"), "<p>{{ .Site.Title }}</p>", 0644},
+	}
+	for _, v := range testdata {
+		info, err := os.Stat(v.path)
+		assert.NilError(t, err)
+		m := iohelpers.NormalizeFileMode(v.mode)
+		assert.Equal(t, m, info.Mode(), v.path)
+		content, err := ioutil.ReadFile(v.path)
+		assert.NilError(t, err)
+		assert.Equal(t, v.content, string(content))
+	}
+}
+
 func TestInputDir_OutputMapInline(t *testing.T) {
 	tmpDir := setupInputDirTest(t)
 	o, e, err := cmd(t,
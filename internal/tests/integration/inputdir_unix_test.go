@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"math"
 	"os"
+	"syscall"
 	"testing"
 
 	"golang.org/x/sys/unix"
@@ -64,3 +65,174 @@ func TestInputDir_RespectsUlimit(t *testing.T) {
 		assert.Equal(t, expected, string(content))
 	}
 }
+
+func statOwner(t *testing.T, path string) (uid, gid uint32) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	assert.NilError(t, err)
+	st := fi.Sys().(*syscall.Stat_t)
+	return st.Uid, st.Gid
+}
+
+func TestInputDir_ChownAndDirMode(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing file ownership requires root")
+	}
+
+	testdir := fs.NewDir(t, "gomplate-inttests",
+		fs.WithDir("in",
+			fs.WithDir("conf", fs.WithFile("app.conf", "port={{ 80 }}")),
+			fs.WithDir("keys", fs.WithFile("app.key", "secret")),
+		),
+	)
+	defer testdir.Remove()
+
+	o, e, err := cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--chown", "1234:2345", "--dir-mode", "0750").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	for _, p := range []string{"out", "out/conf", "out/conf/app.conf", "out/keys/app.key"} {
+		uid, gid := statOwner(t, testdir.Join(p))
+		assert.Equal(t, uint32(1234), uid, p)
+		assert.Equal(t, uint32(2345), gid, p)
+	}
+	fi, err := os.Stat(testdir.Join("out", "conf"))
+	assert.NilError(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+
+	// outputs can inherit ownership from their inputs
+	err = os.Chown(testdir.Join("in", "conf", "app.conf"), 3456, 4567)
+	assert.NilError(t, err)
+	o, e, err = cmd(t, "--input-dir", "in", "--output-dir", "out", "--inherit-owner").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	uid, gid := statOwner(t, testdir.Join("out", "conf", "app.conf"))
+	assert.Equal(t, uint32(3456), uid)
+	assert.Equal(t, uint32(4567), gid)
+	uid, gid = statOwner(t, testdir.Join("out", "keys", "app.key"))
+	assert.Equal(t, uint32(0), uid)
+	assert.Equal(t, uint32(0), gid)
+}
+
+func setupSymlinksTest(t *testing.T) *fs.Dir {
+	t.Helper()
+	testdir := fs.NewDir(t, "gomplate-inttests",
+		fs.WithDir("shared", fs.WithFile("shared.txt", `{{ "shared" | toUpper }}`)),
+		fs.WithDir("in",
+			fs.WithFile("hello.txt", `{{ "hello" }}`),
+		),
+	)
+	t.Cleanup(testdir.Remove)
+
+	// fs.WithSymlink only creates absolute links
+	err := os.Symlink("hello.txt", testdir.Join("in", "alias.txt"))
+	assert.NilError(t, err)
+	err = os.Symlink("../shared", testdir.Join("in", "shared"))
+	assert.NilError(t, err)
+
+	return testdir
+}
+
+func assertSymlink(t *testing.T, path, expected string) {
+	t.Helper()
+	target, err := os.Readlink(path)
+	assert.NilError(t, err)
+	assert.Equal(t, expected, target)
+}
+
+func TestInputDir_SymlinksFollow(t *testing.T) {
+	testdir := setupSymlinksTest(t)
+
+	o, e, err := cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--symlinks", "follow").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	for p, expected := range map[string]string{
+		"hello.txt":         "hello",
+		"alias.txt":         "hello",
+		"shared/shared.txt": "SHARED",
+	} {
+		fi, err := os.Lstat(testdir.Join("out", p))
+		assert.NilError(t, err)
+		assert.Assert(t, fi.Mode().IsRegular(), p)
+		content, err := ioutil.ReadFile(testdir.Join("out", p))
+		assert.NilError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	// the root .gomplateignore applies to paths through followed links
+	err = ioutil.WriteFile(testdir.Join("in", ".gomplateignore"), []byte("shared/secret.txt\n*.bak\n"), 0644)
+	assert.NilError(t, err)
+	err = ioutil.WriteFile(testdir.Join("shared", "secret.txt"), []byte("secret"), 0644)
+	assert.NilError(t, err)
+	err = ioutil.WriteFile(testdir.Join("shared", "notes.bak"), []byte("notes"), 0644)
+	assert.NilError(t, err)
+	o, e, err = cmd(t, "--input-dir", "in", "--output-dir", "ignored",
+		"--symlinks", "follow").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+	_, err = os.Stat(testdir.Join("ignored", "shared", "shared.txt"))
+	assert.NilError(t, err)
+	for _, p := range []string{"shared/secret.txt", "shared/notes.bak"} {
+		_, err = os.Stat(testdir.Join("ignored", p))
+		assert.Assert(t, os.IsNotExist(err), p)
+	}
+
+	// links back into a directory being walked would never end
+	err = os.Symlink("..", testdir.Join("shared", "loop"))
+	assert.NilError(t, err)
+	_, _, err = cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--symlinks", "follow").
+		withDir(testdir.Path()).run()
+	assert.ErrorContains(t, err, "symlink loop detected")
+}
+
+func TestInputDir_SymlinksPreserve(t *testing.T) {
+	testdir := setupSymlinksTest(t)
+	err := os.Symlink(testdir.Join("in", "hello.txt"), testdir.Join("in", "abs.txt"))
+	assert.NilError(t, err)
+	err = os.Symlink("nowhere", testdir.Join("in", "dangling"))
+	assert.NilError(t, err)
+
+	o, e, err := cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--symlinks", "preserve").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	content, err := ioutil.ReadFile(testdir.Join("out", "hello.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "hello", string(content))
+	assertSymlink(t, testdir.Join("out", "alias.txt"), "hello.txt")
+	assertSymlink(t, testdir.Join("out", "shared"), "../shared")
+	assertSymlink(t, testdir.Join("out", "dangling"), "nowhere")
+	assertSymlink(t, testdir.Join("out", "abs.txt"), testdir.Join("in", "hello.txt"))
+
+	// absolute targets in the input dir can be pointed at the output dir
+	o, e, err = cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--symlinks", "preserve", "--rewrite-symlinks").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	assertSymlink(t, testdir.Join("out", "abs.txt"), testdir.Join("out", "hello.txt"))
+	assertSymlink(t, testdir.Join("out", "alias.txt"), "hello.txt")
+	assertSymlink(t, testdir.Join("out", "shared"), "../shared")
+}
+
+func TestInputDir_SymlinksSkip(t *testing.T) {
+	testdir := setupSymlinksTest(t)
+	err := os.Symlink("nowhere", testdir.Join("in", "dangling"))
+	assert.NilError(t, err)
+
+	o, e, err := cmd(t, "--input-dir", "in", "--output-dir", "out",
+		"--symlinks", "skip").
+		withDir(testdir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	files, err := ioutil.ReadDir(testdir.Join("out"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "hello.txt", files[0].Name())
+}
@@ -4,6 +4,7 @@
 package integration
 
 import (
+	"os"
 	"testing"
 
 	"gotest.tools/v3/assert"
@@ -27,6 +28,44 @@ write-error $msg
 exit $code
 `, fs.WithMode(0755)),
 		fs.WithFile("sleep.sh", "#!/bin/sh\n\nexec sleep $1\n", fs.WithMode(0755)),
+		// counts its calls in a file
+		fs.WithFile("count.sh", "#!/bin/sh\n\necho $1 >> calls.txt\necho $1\n", fs.WithMode(0755)),
+		// a minimal JSON-RPC plugin - 'echo' returns its arguments, and 'calls'
+		// returns the request ID, which shows that the process is reused
+		fs.WithFile("rpc.sh", `#!/bin/sh
+while read -r line; do
+  id=$(echo "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+  method=$(echo "$line" | sed 's/.*"method":"\([^"]*\)".*/\1/')
+  params=$(echo "$line" | sed 's/.*"params":\(.*\)}$/\1/')
+  case $method in
+    echo) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":$params}" ;;
+    calls) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"calls\":$id}}" ;;
+    *) echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"error\":{\"code\":-32601,\"message\":\"no such method\"}}" ;;
+  esac
+done
+`, fs.WithMode(0755)),
+		// a plugin with a manifest - the function name is the first argument
+		fs.WithFile("text.sh", `#!/bin/sh
+fn=$1
+shift
+case $fn in
+  upper) echo "$*" | tr a-z A-Z ;;
+  repeat) i=0; while [ $i -lt $2 ]; do printf "%s" "$1"; i=$((i+1)); done ;;
+  keys) sed 's/[{}]//g; s/:[^,]*//g; s/,/\n/g' | sed 's/^/- /' ;;
+esac
+`, fs.WithMode(0755)),
+		fs.WithFile("text.yaml", `namespace: text
+cmd: ./text.sh
+functions:
+  upper:
+    args: [string]
+  repeat:
+    args: [string, number]
+  keys:
+    args: [map]
+    stdin: true
+    output: yaml
+`),
 	)
 	t.Cleanup(tmpDir.Remove)
 
@@ -68,3 +107,126 @@ func TestPlugins_Timeout(t *testing.T) {
 		withEnv("GOMPLATE_PLUGIN_TIMEOUT", "500ms").run()
 	assert.ErrorContains(t, err, "plugin timed out")
 }
+
+func TestPlugins_JSONRPC(t *testing.T) {
+	tmpDir := setupPluginsTest(t)
+	writeFile(tmpDir, ".gomplate.yaml", `plugins:
+  rpc:
+    cmd: `+tmpDir.Join("rpc.sh")+`
+    protocol: jsonrpc
+`)
+
+	o, e, err := cmd(t, "-i", `{{ $r := rpc "echo" 1 "two" (dict "three" 3) -}}
+{{ index $r 0 | math.Add 1 }} {{ index $r 1 }} {{ (index $r 2).three }}
+{{ range seq 3 }}{{ (rpc "calls").calls }} {{ end }}`).
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "2 two 3\n2 3 4 ")
+
+	_, _, err = cmd(t, "-i", `{{ rpc "nope" }}`).
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "nope failed: no such method (code -32601)")
+}
+
+func TestPlugins_Manifest(t *testing.T) {
+	tmpDir := setupPluginsTest(t)
+	writeFile(tmpDir, ".gomplate.yaml", `plugins:
+  textplugin: `+tmpDir.Join("text.yaml")+`
+`)
+
+	o, e, err := cmd(t, "-i", `{{ call text.upper "hello" }}
+{{ call text.repeat "ab" "3" }}
+{{ dict "a" 1 "b" 2 | call text.keys | len }}`).
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "HELLO\n\nababab\n2")
+
+	// templates parsed while rendering can call them too
+	o, e, err = cmd(t, "-i", `{{ tmpl.Inline "t" "{{ call text.upper . }}" "hello" }}`).
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "HELLO\n")
+
+	_, _, err = cmd(t, "-i", `{{ call text.repeat "ab" }}`).
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "wrong number of args for text.repeat: want 2, got 1")
+
+	_, _, err = cmd(t, "-i", `{{ call text.repeat "ab" "many" }}`).
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, `text.repeat: argument 2: expected a number, got "many"`)
+}
+
+func TestPlugins_Stdin(t *testing.T) {
+	tmpDir := setupPluginsTest(t)
+	writeFile(tmpDir, ".gomplate.yaml", `plugins:
+  parse:
+    cmd: cat
+    stdin: true
+    output: json
+  count:
+    cmd: wc
+    stdin: true
+`)
+
+	o, e, err := cmd(t, "-i", `{{ $v := dict "a" (slice 1 2 3) | toJSON | parse -}}
+{{ index $v.a 2 }} {{ (dict "b" true | parse).b }}
+{{ strings.Repeat 200000 "x" | count | strings.Contains "200000" }}`).
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "3 true\ntrue")
+
+	_, _, err = cmd(t, "-i", `{{ "not json" | parse }}`).
+		withDir(tmpDir.Path()).run()
+	assert.ErrorContains(t, err, "failed to parse plugin output as json")
+}
+
+func TestPlugins_Cache(t *testing.T) {
+	tmpDir := setupPluginsTest(t)
+	writeFile(tmpDir, ".gomplate.yaml", `plugins:
+  count:
+    cmd: `+tmpDir.Join("count.sh")+`
+    cache: true
+    maxConcurrency: 1
+`)
+
+	o, e, err := cmd(t, "-i", `{{ range seq 3 }}{{ count "a" }}{{ count "b" }}{{ end }}`).
+		withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "a\nb\na\nb\na\nb\n")
+
+	// each distinct call runs the plugin once
+	calls, err := os.ReadFile(tmpDir.Join("calls.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "a\nb\n", string(calls))
+}
+
+func TestPlugins_Jobs(t *testing.T) {
+	tmpDir := setupPluginsTest(t)
+	writeFile(tmpDir, ".gomplate.yaml", `plugins:
+  count:
+    cmd: `+tmpDir.Join("count.sh")+`
+    cache: true
+datasources:
+  cfg:
+    url: data.json
+jobs:
+  - in: '{{ count "a" }} {{ (ds "cfg").name }}'
+    outputFiles: [one.txt]
+  - in: '{{ count "a" }} {{ (ds "cfg").name }}'
+    outputFiles: [two.txt]
+    datasourceOverrides:
+      cfg:
+        url: 'data:application/json,{"name": "override"}'
+`)
+	writeFile(tmpDir, "data.json", `{"name": "original"}`)
+
+	o, e, err := cmd(t).withDir(tmpDir.Path()).run()
+	assertSuccess(t, o, e, err, "")
+
+	b, err := os.ReadFile(tmpDir.Join("one.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "a\n original", string(b))
+	b, err = os.ReadFile(tmpDir.Join("two.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "a\n override", string(b))
+
+	// the plugin's cache is shared between the jobs
+	calls, err := os.ReadFile(tmpDir.Join("calls.txt"))
+	assert.NilError(t, err)
+	assert.Equal(t, "a\n", string(calls))
+}
@@ -1,6 +1,9 @@
 package gomplate
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Metrics tracks interesting basic metrics around gomplate executions. Warning: experimental!
 // This may change in breaking ways without warning. This is not subject to any semantic versioning guarantees!
@@ -18,11 +21,46 @@ type MetricsType struct {
 
 	TemplatesGathered  int
 	TemplatesProcessed int
-	Errors             int
+	// number of outputs actually written - outputs identical to the existing
+	// file, or suppressed because they were empty, aren't counted
+	OutputsChanged int
+	Errors         int
+
+	// plugin calls, by plugin name - calls answered from the cache aren't
+	// counted in PluginCalls
+	PluginCalls     map[string]int
+	PluginCacheHits map[string]int
+	PluginTimeouts  map[string]int
 }
 
+// pluginMetricsMu - guards the plugin metrics, since plugins may be called
+// concurrently
+var pluginMetricsMu sync.Mutex
+
 func newMetrics() *MetricsType {
 	return &MetricsType{
-		RenderDuration: make(map[string]time.Duration),
+		RenderDuration:  make(map[string]time.Duration),
+		PluginCalls:     make(map[string]int),
+		PluginCacheHits: make(map[string]int),
+		PluginTimeouts:  make(map[string]int),
+	}
+}
+
+// pluginCalled - record a call to the named plugin
+func (m *MetricsType) pluginCalled(name string, cacheHit, timedOut bool) {
+	if m == nil {
+		return
+	}
+	pluginMetricsMu.Lock()
+	defer pluginMetricsMu.Unlock()
+
+	switch {
+	case cacheHit:
+		m.PluginCacheHits[name]++
+	case timedOut:
+		m.PluginCalls[name]++
+		m.PluginTimeouts[name]++
+	default:
+		m.PluginCalls[name]++
 	}
 }
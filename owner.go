@@ -0,0 +1,140 @@
+package gomplate
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+)
+
+// fileOwner - the user and group IDs to give an output. Either may be -1 to
+// leave it unchanged, as with os.Chown.
+type fileOwner struct {
+	uid, gid int
+}
+
+// chown - sets the owner of the named file. A nil owner is a no-op.
+func (o *fileOwner) chown(name string) error {
+	if o == nil {
+		return nil
+	}
+	err := fs.Chown(name, o.uid, o.gid)
+	if err != nil {
+		return fmt.Errorf("failed to chown %s to %d:%d: %w", name, o.uid, o.gid, err)
+	}
+	return nil
+}
+
+// outputOwner - resolves the owner configured by the rule, or nil if the
+// output's ownership shouldn't be changed. When the rule inherits ownership,
+// the owner is read from the input file at inPath, if the platform supports
+// it.
+func outputOwner(rule config.OutputRule, inPath string) (*fileOwner, error) {
+	switch {
+	case rule.Chown != "":
+		return lookupOwner(rule.Chown)
+	case rule.InheritOwner:
+		// inputs from stdin or the commandline have no owner to inherit
+		if inPath == "-" || inPath == "<arg>" {
+			return nil, nil
+		}
+		fi, err := fs.Stat(inPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", inPath, err)
+		}
+		uid, gid, ok := fileOwnerIDs(fi)
+		if !ok {
+			// ownership isn't available on all platforms (i.e. Windows), in
+			// which case there's nothing to inherit
+			return nil, nil
+		}
+		return &fileOwner{uid: uid, gid: gid}, nil
+	}
+	return nil, nil
+}
+
+// lookupOwner - resolves a 'user:group' spec, where either can be a name or a
+// numeric ID
+func lookupOwner(spec string) (*fileOwner, error) {
+	u, g, err := config.ParseChown(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &fileOwner{uid: -1, gid: -1}
+	if u != "" {
+		o.uid, err = lookupID(u, func(name string) (string, error) {
+			usr, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return usr.Uid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unknown user %q: %w", u, err)
+		}
+	}
+	if g != "" {
+		o.gid, err = lookupID(g, func(name string) (string, error) {
+			grp, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return grp.Gid, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unknown group %q: %w", g, err)
+		}
+	}
+	return o, nil
+}
+
+func lookupID(name string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+	id, err := lookup(name)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+// mkdirAll - like MkdirAll, but when modeOverride is set, directories are
+// created with exactly the given mode, regardless of the umask. Any
+// directories created are given to the owner, if it's not nil.
+func mkdirAll(dir string, mode os.FileMode, modeOverride bool, owner *fileOwner) error {
+	fi, err := fs.Stat(dir)
+	if err == nil {
+		if !fi.IsDir() {
+			return fmt.Errorf("can't create directory %s: a file with that name exists", dir)
+		}
+		return nil
+	}
+
+	parent := filepath.Dir(dir)
+	if parent != dir {
+		err = mkdirAll(parent, mode, modeOverride, owner)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = fs.Mkdir(dir, mode)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	if modeOverride {
+		err = fs.Chmod(dir, mode)
+		if err != nil {
+			return fmt.Errorf("failed to chmod directory %s with mode %q: %w", dir, mode, err)
+		}
+	}
+	return owner.chown(dir)
+}
@@ -0,0 +1,101 @@
+package gomplate
+
+import (
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupOwner(t *testing.T) {
+	o, err := lookupOwner("1000:2000")
+	assert.NoError(t, err)
+	assert.Equal(t, &fileOwner{uid: 1000, gid: 2000}, o)
+
+	o, err = lookupOwner("1000")
+	assert.NoError(t, err)
+	assert.Equal(t, &fileOwner{uid: 1000, gid: -1}, o)
+
+	o, err = lookupOwner(":2000")
+	assert.NoError(t, err)
+	assert.Equal(t, &fileOwner{uid: -1, gid: 2000}, o)
+
+	_, err = lookupOwner("a:b:c")
+	assert.Error(t, err)
+
+	_, err = lookupOwner("no-such-user-here")
+	assert.Error(t, err)
+
+	_, err = lookupOwner(":no-such-group-here")
+	assert.Error(t, err)
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	cur, err := user.Current()
+	require.NoError(t, err)
+	uid, _ := strconv.Atoi(cur.Uid)
+
+	o, err = lookupOwner(cur.Username)
+	assert.NoError(t, err)
+	assert.Equal(t, &fileOwner{uid: uid, gid: -1}, o)
+}
+
+func TestOutputOwner(t *testing.T) {
+	o, err := outputOwner(config.OutputRule{}, "in")
+	assert.NoError(t, err)
+	assert.Nil(t, o)
+
+	o, err = outputOwner(config.OutputRule{Chown: "1:2"}, "in")
+	assert.NoError(t, err)
+	assert.Equal(t, &fileOwner{uid: 1, gid: 2}, o)
+
+	// nothing to inherit from
+	o, err = outputOwner(config.OutputRule{InheritOwner: true}, "-")
+	assert.NoError(t, err)
+	assert.Nil(t, o)
+
+	o, err = outputOwner(config.OutputRule{InheritOwner: true}, "<arg>")
+	assert.NoError(t, err)
+	assert.Nil(t, o)
+
+	_, err = outputOwner(config.OutputRule{InheritOwner: true}, "/no/such/file")
+	assert.Error(t, err)
+}
+
+func TestMkdirAll(t *testing.T) {
+	origfs := fs
+	defer func() { fs = origfs }()
+	fs = afero.NewMemMapFs()
+
+	err := mkdirAll("/a/b/c", 0750, true, &fileOwner{uid: 1, gid: 2})
+	assert.NoError(t, err)
+
+	for _, d := range []string{"/a", "/a/b", "/a/b/c"} {
+		fi, err := fs.Stat(d)
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+		assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	}
+
+	// existing directories are left alone
+	err = mkdirAll("/a/b/d", 0700, true, nil)
+	assert.NoError(t, err)
+	fi, err := fs.Stat("/a/b")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+	fi, err = fs.Stat("/a/b/d")
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	_ = afero.WriteFile(fs, "/a/file", []byte("hi"), 0644)
+	err = mkdirAll("/a/file/sub", 0755, false, nil)
+	assert.Error(t, err)
+}
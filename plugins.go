@@ -3,43 +3,199 @@ package gomplate
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"text/template"
 	"time"
 
 	"github.com/hairyhenderson/gomplate/v3/conv"
+	"github.com/hairyhenderson/gomplate/v3/data"
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
 )
 
-func bindPlugins(ctx context.Context, cfg *config.Config, funcMap template.FuncMap) error {
+// boundPlugins - the functions bound for each plugin, by name, so that plugins
+// shared between jobs are only started once, and share their caches. Plugins
+// are always bound afresh when the map is nil.
+type boundPlugins map[string]boundPlugin
+
+type boundPlugin struct {
+	cfg     config.PluginConfig
+	timeout time.Duration
+	funcs   map[string]interface{}
+}
+
+func bindPlugins(ctx context.Context, cfg *config.Config, funcMap template.FuncMap, bound boundPlugins) error {
 	for k, v := range cfg.Plugins {
-		plugin := &plugin{
-			ctx:     ctx,
-			name:    k,
-			path:    v,
-			timeout: cfg.PluginTimeout,
-			stderr:  cfg.Stderr,
+		b, ok := bound[k]
+		if !ok || !reflect.DeepEqual(b.cfg, v) || b.timeout != cfg.PluginTimeout {
+			funcs, err := pluginFuncs(ctx, cfg, k, v)
+			if err != nil {
+				return err
+			}
+			b = boundPlugin{cfg: v, timeout: cfg.PluginTimeout, funcs: funcs}
+			if bound != nil {
+				bound[k] = b
+			}
 		}
-		if _, ok := funcMap[plugin.name]; ok {
-			return fmt.Errorf("function %q is already bound, and can not be overridden", plugin.name)
+
+		for name, f := range b.funcs {
+			if _, ok := funcMap[name]; ok {
+				return fmt.Errorf("function %q is already bound, and can not be overridden", name)
+			}
+			funcMap[name] = f
 		}
-		funcMap[plugin.name] = plugin.run
 	}
 	return nil
 }
 
+// pluginFuncs - start the plugin, if needed, and return its functions by name
+func pluginFuncs(ctx context.Context, cfg *config.Config, name string, v config.PluginConfig) (map[string]interface{}, error) {
+	plugin := &plugin{
+		ctx:     ctx,
+		name:    name,
+		path:    v.Cmd,
+		timeout: cfg.PluginTimeout,
+		stderr:  cfg.Stderr,
+	}
+	calls := newPluginCalls(name, v)
+
+	funcs := map[string]interface{}{}
+	switch {
+	case config.IsPluginManifest(v.Cmd):
+		ns, f, err := bindManifest(plugin, calls)
+		if err != nil {
+			return nil, err
+		}
+		funcs[ns] = f
+	case v.GetProtocol() == config.PluginProtocolJSONRPC:
+		p := &rpcPlugin{plugin: plugin}
+		addCleanupHook(p.close)
+		funcs[plugin.name] = func(method string, args ...interface{}) (interface{}, error) {
+			return calls.do(method, args, func() (interface{}, error) {
+				return p.call(method, args...)
+			})
+		}
+	case v.GetProtocol() == config.PluginProtocolWasm:
+		// WebAssembly plugins bind each exported function by name
+		p, err := newWasmPlugin(plugin, v.Mounts)
+		if err != nil {
+			return nil, err
+		}
+		addCleanupHook(p.close)
+		for _, name := range p.functions() {
+			funcs[name] = calls.wrap(name, p.fn(name))
+		}
+	default:
+		plugin.pipe = v.Stdin
+		plugin.output = v.Output
+		funcs[plugin.name] = calls.wrap(plugin.name, plugin.run)
+	}
+	return funcs, nil
+}
+
+// pluginCalls - caches results and limits concurrency for calls to a plugin,
+// and records metrics
+type pluginCalls struct {
+	name    string
+	metrics *MetricsType
+
+	// cached results by function and arguments, when caching is enabled
+	mu    sync.Mutex
+	cache map[string]interface{}
+
+	// limits concurrent calls, when set
+	sem chan struct{}
+}
+
+func newPluginCalls(name string, cfg config.PluginConfig) *pluginCalls {
+	c := &pluginCalls{name: name, metrics: Metrics}
+	if cfg.Cache {
+		c.cache = map[string]interface{}{}
+	}
+	if cfg.MaxConcurrency > 0 {
+		c.sem = make(chan struct{}, cfg.MaxConcurrency)
+	}
+	return c
+}
+
+// wrap - wrap the function so its calls go through do
+func (c *pluginCalls) wrap(fn string, f pluginFunc) pluginFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		return c.do(fn, args, func() (interface{}, error) {
+			return f(args...)
+		})
+	}
+}
+
+// do - call the function with the given arguments, or return a cached result
+func (c *pluginCalls) do(fn string, args []interface{}, call func() (interface{}, error)) (interface{}, error) {
+	key, cacheable := c.cacheKey(fn, args)
+	if cacheable {
+		c.mu.Lock()
+		out, ok := c.cache[key]
+		c.mu.Unlock()
+		if ok {
+			c.metrics.pluginCalled(c.name, true, false)
+			return out, nil
+		}
+	}
+
+	if c.sem != nil {
+		c.sem <- struct{}{}
+		defer func() { <-c.sem }()
+	}
+
+	out, err := call()
+	c.metrics.pluginCalled(c.name, false, errors.Is(err, context.DeadlineExceeded))
+	if err == nil && cacheable {
+		c.mu.Lock()
+		c.cache[key] = out
+		c.mu.Unlock()
+	}
+	return out, err
+}
+
+// cacheKey - the key to cache the call's result with. Calls with arguments
+// that can't be compared (such as readers) aren't cached.
+func (c *pluginCalls) cacheKey(fn string, args []interface{}) (string, bool) {
+	if c.cache == nil {
+		return "", false
+	}
+	for _, a := range args {
+		if _, ok := a.(io.Reader); ok {
+			return "", false
+		}
+	}
+	b, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return fn + "\x00" + string(b), true
+}
+
 // plugin represents a custom function that binds to an external process to be executed
 type plugin struct {
 	ctx        context.Context
 	stderr     io.Writer
 	name, path string
 	timeout    time.Duration
+
+	// args - given before the arguments from the template
+	args []string
+	// pipe - when true, the last argument is written to standard input
+	pipe bool
+	// output - the format to parse the output as
+	output string
 }
 
 // builds a command that's appropriate for running scripts
@@ -73,14 +229,24 @@ func findPowershell() string {
 }
 
 func (p *plugin) run(args ...interface{}) (interface{}, error) {
-	a := conv.ToStrings(args...)
+	var stdin io.Reader
+	if p.pipe && len(args) > 0 {
+		var err error
+		stdin, err = pluginInput(args[len(args)-1])
+		if err != nil {
+			return nil, err
+		}
+		args = args[:len(args)-1]
+	}
+
+	a := append(append([]string{}, p.args...), conv.ToStrings(args...)...)
 
 	name, a := p.buildCommand(a)
 
 	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
 	defer cancel()
 	c := exec.CommandContext(ctx, name, a...)
-	c.Stdin = nil
+	c.Stdin = stdin
 	c.Stderr = p.stderr
 	outBuf := &bytes.Buffer{}
 	c.Stdout = outBuf
@@ -112,6 +278,57 @@ func (p *plugin) run(args ...interface{}) (interface{}, error) {
 	if ctx.Err() != nil {
 		err = fmt.Errorf("plugin timed out after %v: %w", elapsed, ctx.Err())
 	}
+	if err != nil {
+		return outBuf.String(), err
+	}
+
+	return parsePluginOutput(p.output, outBuf.String())
+}
+
+// pluginInput - the value to stream to a plugin's standard input. Maps and
+// lists are encoded as JSON.
+func pluginInput(in interface{}) (io.Reader, error) {
+	switch v := in.(type) {
+	case io.Reader:
+		return v, nil
+	case string:
+		return strings.NewReader(v), nil
+	case []byte:
+		return bytes.NewReader(v), nil
+	}
 
-	return outBuf.String(), err
+	switch reflect.ValueOf(in).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		s, err := data.ToJSON(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode plugin input: %w", err)
+		}
+		return strings.NewReader(s), nil
+	}
+	return strings.NewReader(conv.ToString(in)), nil
+}
+
+// parsePluginOutput - parse the plugin's output in the given format
+func parsePluginOutput(format, out string) (interface{}, error) {
+	var parse, parseArray func(string) (interface{}, error)
+	switch format {
+	case config.PluginOutputJSON:
+		parse = func(s string) (interface{}, error) { return data.JSON(s) }
+		parseArray = func(s string) (interface{}, error) { return data.JSONArray(s) }
+	case config.PluginOutputYAML:
+		parse = func(s string) (interface{}, error) { return data.YAML(s) }
+		parseArray = func(s string) (interface{}, error) { return data.YAMLArray(s) }
+	default:
+		return out, nil
+	}
+
+	v, err := parse(out)
+	if err != nil {
+		// it may be a list instead
+		if a, aerr := parseArray(out); aerr == nil {
+			return a, nil
+		}
+		return nil, fmt.Errorf("failed to parse plugin output as %s: %w", format, err)
+	}
+	return v, nil
 }
@@ -0,0 +1,199 @@
+package gomplate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// rpcPlugin - a plugin which is started once, and then called with JSON-RPC
+// 2.0 requests written to its standard input, one per line. Responses are read
+// from its standard output. Arguments and results can be any JSON-compatible
+// value, and the method name allows one plugin to provide several functions.
+type rpcPlugin struct {
+	*plugin
+
+	// calls are made one at a time
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	dec    *json.Decoder
+	lastID int
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+// value - the decoded result, or the error returned by the method
+func (r rpcResponse) value(method string) (interface{}, error) {
+	if r.Error != nil {
+		return nil, fmt.Errorf("%s failed: %w", method, r.Error)
+	}
+
+	var out interface{}
+	if len(r.Result) > 0 {
+		err := json.Unmarshal(r.Result, &out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode result of %s: %w", method, err)
+		}
+	}
+	return out, nil
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+type rpcResult struct {
+	resp rpcResponse
+	err  error
+}
+
+// call - call the plugin's method with the given arguments. The plugin is
+// started on the first call.
+func (p *rpcPlugin) call(method string, args ...interface{}) (interface{}, error) {
+	return p.callWithTimeout(p.timeout, method, args...)
+}
+
+// callWithTimeout - call the plugin's method, overriding the plugin's timeout
+func (p *rpcPlugin) callWithTimeout(timeout time.Duration, method string, args ...interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		err := p.start()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.lastID++
+	req := rpcRequest{JSONRPC: "2.0", ID: p.lastID, Method: method, Params: args}
+	if req.Params == nil {
+		req.Params = []interface{}{}
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments for %s: %w", method, err)
+	}
+	b = append(b, '\n')
+
+	ctx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	results := make(chan rpcResult, 1)
+	stdin, dec := p.stdin, p.dec
+	go func() {
+		_, err := stdin.Write(b)
+		if err != nil {
+			results <- rpcResult{err: err}
+			return
+		}
+		resp := rpcResponse{}
+		err = dec.Decode(&resp)
+		results <- rpcResult{resp: resp, err: err}
+	}()
+
+	var r rpcResult
+	select {
+	case r = <-results:
+	case <-ctx.Done():
+		// the plugin's in an unknown state, so it'll be restarted on the next call
+		p.stop()
+		return nil, fmt.Errorf("plugin timed out after %v: %w", time.Since(start), ctx.Err())
+	}
+
+	if r.err != nil {
+		p.stop()
+		return nil, fmt.Errorf("failed to call %s: %w", method, r.err)
+	}
+	if r.resp.ID != req.ID {
+		p.stop()
+		return nil, fmt.Errorf("failed to call %s: got response with id %d, expected %d", method, r.resp.ID, req.ID)
+	}
+	return r.resp.value(method)
+}
+
+func (p *rpcPlugin) start() error {
+	name, args := p.buildCommand(nil)
+
+	// nolint: gosec
+	c := exec.CommandContext(p.ctx, name, args...)
+	c.Stderr = p.stderr
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = c.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start plugin %s: %w", p.name, err)
+	}
+
+	p.cmd = c
+	p.stdin = stdin
+	p.dec = json.NewDecoder(stdout)
+	return nil
+}
+
+// stop - kill the plugin. Must be called with the lock held.
+func (p *rpcPlugin) stop() {
+	if p.cmd == nil {
+		return
+	}
+	_ = p.stdin.Close()
+	_ = p.cmd.Process.Kill()
+	_ = p.cmd.Wait()
+	p.cmd, p.stdin, p.dec = nil, nil, nil
+}
+
+// close - shut the plugin down by closing its standard input, killing it if
+// it doesn't exit within the timeout
+func (p *rpcPlugin) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		return
+	}
+	_ = p.stdin.Close()
+
+	done := make(chan struct{})
+	go func(c *exec.Cmd) {
+		_ = c.Wait()
+		close(done)
+	}(p.cmd)
+
+	select {
+	case <-done:
+	case <-time.After(p.timeout):
+		_ = p.cmd.Process.Kill()
+		<-done
+	}
+	p.cmd, p.stdin, p.dec = nil, nil, nil
+}
@@ -0,0 +1,141 @@
+package gomplate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// when the test binary is run as a JSON-RPC plugin, it serves requests
+// instead of running the tests
+const rpcPluginEnvVar = "GOMPLATE_TEST_RPC_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(rpcPluginEnvVar) == "1" {
+		serveTestRPCPlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func serveTestRPCPlugin() {
+	s := bufio.NewScanner(os.Stdin)
+	enc := json.NewEncoder(os.Stdout)
+	for s.Scan() {
+		req := struct {
+			ID     int
+			Method string
+			Params []interface{}
+		}{}
+		_ = json.Unmarshal(s.Bytes(), &req)
+
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "echo":
+			resp["result"] = req.Params
+		case "upper":
+			resp["result"] = strings.ToUpper(req.Params[0].(string))
+		case "keys":
+			keys := []string{}
+			for k := range req.Params[0].(map[string]interface{}) {
+				keys = append(keys, k)
+			}
+			resp["result"] = keys
+		case "pid":
+			resp["result"] = os.Getpid()
+		case "sleep":
+			time.Sleep(time.Second)
+			resp["result"] = nil
+		default:
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+		_ = enc.Encode(resp)
+	}
+}
+
+func testRPCPlugin(t *testing.T, timeout time.Duration) *rpcPlugin {
+	t.Helper()
+	t.Setenv(rpcPluginEnvVar, "1")
+	p := &rpcPlugin{plugin: &plugin{
+		ctx:     context.Background(),
+		name:    "test",
+		path:    os.Args[0],
+		timeout: timeout,
+		stderr:  &bytes.Buffer{},
+	}}
+	t.Cleanup(p.close)
+	return p
+}
+
+func TestRPCPluginCall(t *testing.T) {
+	p := testRPCPlugin(t, 5*time.Second)
+
+	out, err := p.call("upper", "hello")
+	assert.NilError(t, err)
+	assert.Equal(t, "HELLO", out)
+
+	// arguments and results are typed
+	out, err = p.call("echo", 42, true, []interface{}{"a", "b"}, map[string]interface{}{"k": "v"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{42.0, true, []interface{}{"a", "b"}, map[string]interface{}{"k": "v"}}, out)
+
+	out, err = p.call("keys", map[string]interface{}{"foo": 1})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"foo"}, out)
+
+	_, err = p.call("nope")
+	assert.ErrorContains(t, err, "nope failed: method not found (code -32601)")
+
+	// the same process serves every call
+	pid, err := p.call("pid")
+	assert.NilError(t, err)
+	pid2, err := p.call("pid")
+	assert.NilError(t, err)
+	assert.Equal(t, pid, pid2)
+
+	// after closing, the plugin's restarted on the next call
+	p.close()
+	pid2, err = p.call("pid")
+	assert.NilError(t, err)
+	assert.Assert(t, pid != pid2)
+}
+
+func TestRPCPluginTimeout(t *testing.T) {
+	p := testRPCPlugin(t, 100*time.Millisecond)
+
+	pid, err := p.call("pid")
+	assert.NilError(t, err)
+
+	_, err = p.call("sleep")
+	assert.ErrorContains(t, err, "plugin timed out")
+
+	// a plugin that timed out is restarted
+	pid2, err := p.call("pid")
+	assert.NilError(t, err)
+	assert.Assert(t, pid != pid2)
+}
+
+func TestBindRPCPlugin(t *testing.T) {
+	fm := template.FuncMap{}
+	cfg := &config.Config{
+		Plugins: map[string]config.PluginConfig{
+			"text": {Cmd: "textplugin", Protocol: config.PluginProtocolJSONRPC},
+		},
+	}
+	err := bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(fm, "text"))
+
+	_, ok := fm["text"].(func(string, ...interface{}) (interface{}, error))
+	assert.Assert(t, ok)
+}
@@ -0,0 +1,173 @@
+package gomplate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hairyhenderson/gomplate/v3/conv"
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+)
+
+// pluginFunc - the signature of the functions in a plugin's namespace
+type pluginFunc = func(args ...interface{}) (interface{}, error)
+
+// pluginNamespace - the functions declared by a plugin manifest, by name.
+// Templates can only give arguments to methods, and these functions are only
+// known at runtime, so they're called with call, as in `call ns.fn "arg"`.
+type pluginNamespace map[string]pluginFunc
+
+// bindManifest - read the plugin manifest at the plugin's path, and return the
+// namespace it declares, along with a function returning the namespace (like
+// the built-in namespaces)
+func bindManifest(p *plugin, calls *pluginCalls) (string, func() pluginNamespace, error) {
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read plugin manifest for %s: %w", p.name, err)
+	}
+	m, err := config.ParsePluginManifest(bytes.NewReader(b))
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+	err = m.Validate()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", p.path, err)
+	}
+
+	ns := m.Namespace
+	if ns == "" {
+		ns = p.name
+	}
+
+	base := *p
+	base.name = ns
+	base.path = m.Cmd
+	// commands with a path are relative to the manifest, others are looked
+	// up in the PATH
+	if !filepath.IsAbs(m.Cmd) && strings.ContainsAny(m.Cmd, `/\`) {
+		base.path = filepath.Join(filepath.Dir(p.path), m.Cmd)
+	}
+
+	var caller func(name string, timeout time.Duration, f config.PluginFunction) (pluginFunc, error)
+	switch m.Plugin().GetProtocol() {
+	case config.PluginProtocolJSONRPC:
+		rp := &rpcPlugin{plugin: &base}
+		addCleanupHook(rp.close)
+		caller = func(name string, timeout time.Duration, _ config.PluginFunction) (pluginFunc, error) {
+			return func(args ...interface{}) (interface{}, error) {
+				return rp.callWithTimeout(timeout, name, args...)
+			}, nil
+		}
+	case config.PluginProtocolWasm:
+		wp, err := newWasmPlugin(&base, m.Mounts)
+		if err != nil {
+			return "", nil, err
+		}
+		addCleanupHook(wp.close)
+		exported := map[string]bool{}
+		for _, name := range wp.functions() {
+			exported[name] = true
+		}
+		caller = func(name string, timeout time.Duration, _ config.PluginFunction) (pluginFunc, error) {
+			if !exported[name] {
+				return nil, fmt.Errorf("plugin %s does not export function %s", ns, name)
+			}
+			return func(args ...interface{}) (interface{}, error) {
+				return wp.callWithTimeout(timeout, name, args...)
+			}, nil
+		}
+	default:
+		// each function runs the command with the function name as the first
+		// argument
+		caller = func(name string, timeout time.Duration, f config.PluginFunction) (pluginFunc, error) {
+			fp := base
+			fp.args = []string{name}
+			fp.timeout = timeout
+			fp.pipe = f.Stdin
+			fp.output = f.Output
+			return fp.run, nil
+		}
+	}
+
+	funcs := make(pluginNamespace, len(m.Functions))
+	for name, f := range m.Functions {
+		timeout := p.timeout
+		if f.Timeout > 0 {
+			timeout = f.Timeout
+		}
+		fn, err := caller(name, timeout, f)
+		if err != nil {
+			return "", nil, err
+		}
+		funcs[name] = checkPluginArgs(ns+"."+name, f.Args, calls.wrap(name, fn))
+	}
+
+	return ns, func() pluginNamespace { return funcs }, nil
+}
+
+// checkPluginArgs - wraps the function to check the number and types of its
+// arguments, converting them where possible
+func checkPluginArgs(name string, types []string, f pluginFunc) pluginFunc {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != len(types) {
+			return nil, fmt.Errorf("wrong number of args for %s: want %d, got %d", name, len(types), len(args))
+		}
+		converted := make([]interface{}, len(args))
+		for i, arg := range args {
+			v, err := convertPluginArg(types[i], arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: argument %d: %w", name, i+1, err)
+			}
+			converted[i] = v
+		}
+		return f(converted...)
+	}
+}
+
+// convertPluginArg - convert the argument to the declared type
+func convertPluginArg(typ string, arg interface{}) (interface{}, error) {
+	v := reflect.ValueOf(arg)
+	switch typ {
+	case config.PluginArgString:
+		return conv.ToString(arg), nil
+	case config.PluginArgNumber:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return arg, nil
+		case reflect.String:
+			if i, err := strconv.ParseInt(v.String(), 0, 64); err == nil {
+				return i, nil
+			}
+			if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a number, got %q", conv.ToString(arg))
+	case config.PluginArgBool:
+		switch v.Kind() {
+		case reflect.Bool:
+			return arg, nil
+		case reflect.String:
+			if b, err := strconv.ParseBool(v.String()); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("expected a bool, got %q", conv.ToString(arg))
+	case config.PluginArgMap:
+		if v.Kind() != reflect.Map {
+			return nil, fmt.Errorf("expected a map, got %T", arg)
+		}
+	case config.PluginArgList:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("expected a list, got %T", arg)
+		}
+	}
+	return arg, nil
+}
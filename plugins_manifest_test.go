@@ -0,0 +1,221 @@
+package gomplate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+)
+
+func TestConvertPluginArg(t *testing.T) {
+	data := []struct {
+		typ      string
+		in       interface{}
+		expected interface{}
+	}{
+		{config.PluginArgString, "foo", "foo"},
+		{config.PluginArgString, 42, "42"},
+		{config.PluginArgNumber, 42, 42},
+		{config.PluginArgNumber, 4.2, 4.2},
+		{config.PluginArgNumber, "42", int64(42)},
+		{config.PluginArgNumber, "0x10", int64(16)},
+		{config.PluginArgNumber, "4.2", 4.2},
+		{config.PluginArgBool, true, true},
+		{config.PluginArgBool, "false", false},
+		{config.PluginArgMap, map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1}},
+		{config.PluginArgList, []string{"a"}, []string{"a"}},
+		{config.PluginArgList, [1]int{1}, [1]int{1}},
+		{config.PluginArgAny, nil, nil},
+		{config.PluginArgAny, 42, 42},
+	}
+	for _, d := range data {
+		out, err := convertPluginArg(d.typ, d.in)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, d.expected, out)
+	}
+
+	_, err := convertPluginArg(config.PluginArgNumber, "foo")
+	assert.ErrorContains(t, err, `expected a number, got "foo"`)
+	_, err = convertPluginArg(config.PluginArgNumber, true)
+	assert.ErrorContains(t, err, "expected a number")
+	_, err = convertPluginArg(config.PluginArgBool, "maybe")
+	assert.ErrorContains(t, err, `expected a bool, got "maybe"`)
+	_, err = convertPluginArg(config.PluginArgMap, []string{})
+	assert.ErrorContains(t, err, "expected a map, got []string")
+	_, err = convertPluginArg(config.PluginArgList, "foo")
+	assert.ErrorContains(t, err, "expected a list, got string")
+}
+
+func TestCheckPluginArgs(t *testing.T) {
+	echo := func(args ...interface{}) (interface{}, error) {
+		return args, nil
+	}
+	f := checkPluginArgs("ns.fn", []string{config.PluginArgString, config.PluginArgNumber}, echo)
+
+	out, err := f(1, "2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"1", int64(2)}, out)
+
+	_, err = f("foo")
+	assert.ErrorContains(t, err, "wrong number of args for ns.fn: want 2, got 1")
+
+	_, err = f("foo", "bar")
+	assert.ErrorContains(t, err, `ns.fn: argument 2: expected a number, got "bar"`)
+}
+
+func TestParsePluginOutput(t *testing.T) {
+	out, err := parsePluginOutput("", `{"a": 1}`)
+	assert.NilError(t, err)
+	assert.Equal(t, `{"a": 1}`, out)
+
+	out, err = parsePluginOutput(config.PluginOutputJSON, `{"a": 1}`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"a": 1}, out)
+
+	out, err = parsePluginOutput(config.PluginOutputJSON, `["a", "b"]`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"a", "b"}, out)
+
+	out, err = parsePluginOutput(config.PluginOutputYAML, "a: b\n")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"a": "b"}, out)
+
+	out, err = parsePluginOutput(config.PluginOutputYAML, "- a\n- b\n")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"a", "b"}, out)
+
+	_, err = parsePluginOutput(config.PluginOutputJSON, "not json")
+	assert.ErrorContains(t, err, "failed to parse plugin output as json")
+}
+
+func TestRunPipe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no cat on Windows")
+	}
+
+	p := &plugin{
+		ctx:     context.Background(),
+		timeout: 5 * time.Second,
+		path:    "cat",
+		pipe:    true,
+		output:  config.PluginOutputJSON,
+	}
+	out, err := p.run(map[string]interface{}{"foo": []string{"bar"}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"foo": []interface{}{"bar"}}, out)
+}
+
+func TestBindManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "text.yaml")
+	err := os.WriteFile(manifest, []byte(`namespace: text
+cmd: echo
+functions:
+  shout:
+    args: [string, number]
+  whisper:
+    args: [string]
+    timeout: 2s
+`), 0600)
+	assert.NilError(t, err)
+
+	fm := template.FuncMap{}
+	cfg := &config.Config{
+		Plugins: map[string]config.PluginConfig{
+			"textplugin": {Cmd: manifest},
+		},
+		PluginTimeout: 5 * time.Second,
+	}
+	err = bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+	_, ok := fm["textplugin"]
+	assert.Check(t, !ok)
+	assert.Check(t, cmp.Contains(fm, "text"))
+
+	ns := fm["text"].(func() pluginNamespace)()
+	assert.Check(t, cmp.Len(ns, 2))
+
+	if runtime.GOOS != "windows" {
+		out, err := ns["shout"]("hello", 3)
+		assert.NilError(t, err)
+		assert.Equal(t, "shout hello 3", strings.TrimSpace(out.(string)))
+	}
+
+	_, err = ns["whisper"]()
+	assert.ErrorContains(t, err, "wrong number of args for text.whisper")
+
+	// the namespace defaults to the plugin's name
+	err = os.WriteFile(manifest, []byte("cmd: echo\nfunctions: {foo: {}}\n"), 0600)
+	assert.NilError(t, err)
+	fm = template.FuncMap{}
+	err = bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(fm, "textplugin"))
+
+	err = os.WriteFile(manifest, []byte("cmd: echo\nfunctions: {}\n"), 0600)
+	assert.NilError(t, err)
+	err = bindPlugins(context.Background(), cfg, template.FuncMap{}, nil)
+	assert.ErrorContains(t, err, "plugin manifest declares no functions")
+
+	cfg.Plugins["textplugin"] = config.PluginConfig{Cmd: filepath.Join(dir, "missing.yaml")}
+	err = bindPlugins(context.Background(), cfg, template.FuncMap{}, nil)
+	assert.ErrorContains(t, err, "failed to read plugin manifest for textplugin")
+}
+
+func TestPluginNamespaceCalls(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no echo on Windows")
+	}
+
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "text.yaml")
+	err := os.WriteFile(manifest, []byte(`namespace: text
+cmd: echo
+functions:
+  shout:
+    args: [string]
+`), 0600)
+	assert.NilError(t, err)
+
+	fm := template.FuncMap{"upper": strings.ToUpper}
+	cfg := &config.Config{
+		Plugins:       map[string]config.PluginConfig{"text": {Cmd: manifest}},
+		PluginTimeout: 5 * time.Second,
+	}
+	err = bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+
+	render := func(in string) (string, error) {
+		tmpl, err := template.New("t").Funcs(fm).Parse(in)
+		assert.NilError(t, err)
+		out := &bytes.Buffer{}
+		err = tmpl.Execute(out, nil)
+		return strings.ReplaceAll(out.String(), "\n", ""), err
+	}
+
+	for in, expected := range map[string]string{
+		`{{ call text.shout "x" }}`:                                                "shout x",
+		`{{ "x" | call text.shout }}`:                                              "shout x",
+		`{{ upper (call text.shout "x") }}`:                                        "SHOUT X",
+		`{{ if true }}{{ call text.shout "x" }}{{ end }}`:                          "shout x",
+		`{{ define "t2" }}{{ call text.shout . }}{{ end }}{{ template "t2" "y" }}`: "shout y",
+		`{{ $f := text.shout }}{{ call $f "x" }}`:                                  "shout x",
+	} {
+		out, err := render(in)
+		assert.NilError(t, err, in)
+		assert.Equal(t, expected, out, in)
+	}
+
+	_, err = render(`{{ call text.whisper "x" }}`)
+	assert.ErrorContains(t, err, "call of nil")
+}
@@ -3,7 +3,10 @@ package gomplate
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"text/template"
 	"time"
@@ -18,18 +21,18 @@ func TestBindPlugins(t *testing.T) {
 	ctx := context.Background()
 	fm := template.FuncMap{}
 	cfg := &config.Config{
-		Plugins: map[string]string{},
+		Plugins: map[string]config.PluginConfig{},
 	}
-	err := bindPlugins(ctx, cfg, fm)
+	err := bindPlugins(ctx, cfg, fm, nil)
 	assert.NilError(t, err)
 	assert.DeepEqual(t, template.FuncMap{}, fm)
 
-	cfg.Plugins = map[string]string{"foo": "bar"}
-	err = bindPlugins(ctx, cfg, fm)
+	cfg.Plugins = map[string]config.PluginConfig{"foo": {Cmd: "bar"}}
+	err = bindPlugins(ctx, cfg, fm, nil)
 	assert.NilError(t, err)
 	assert.Check(t, cmp.Contains(fm, "foo"))
 
-	err = bindPlugins(ctx, cfg, fm)
+	err = bindPlugins(ctx, cfg, fm, nil)
 	assert.ErrorContains(t, err, "already bound")
 }
 
@@ -74,3 +77,123 @@ func TestRun(t *testing.T) {
 	assert.Equal(t, "", stderr.String())
 	assert.Equal(t, "foo", strings.TrimSpace(out.(string)))
 }
+
+func TestBindPluginsStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no cat on Windows")
+	}
+
+	fm := template.FuncMap{}
+	cfg := &config.Config{
+		Plugins: map[string]config.PluginConfig{
+			"parse": {Cmd: "cat", Stdin: true, Output: config.PluginOutputYAML},
+		},
+		PluginTimeout: 5 * time.Second,
+	}
+	err := bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+
+	f := fm["parse"].(func(...interface{}) (interface{}, error))
+	out, err := f("foo: [bar, baz]\n")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"foo": []interface{}{"bar", "baz"}}, out)
+
+	// readers are streamed as-is
+	out, err = f(strings.NewReader("- a\n- b\n"))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"a", "b"}, out)
+}
+
+func TestPluginCalls(t *testing.T) {
+	origMetrics := Metrics
+	defer func() { Metrics = origMetrics }()
+	Metrics = newMetrics()
+
+	n := 0
+	f := func(args ...interface{}) (interface{}, error) {
+		n++
+		return n, nil
+	}
+
+	// no caching by default
+	calls := newPluginCalls("foo", config.PluginConfig{})
+	fn := calls.wrap("foo", f)
+	out, _ := fn("a")
+	assert.Equal(t, 1, out)
+	out, _ = fn("a")
+	assert.Equal(t, 2, out)
+	assert.Equal(t, 2, Metrics.PluginCalls["foo"])
+	assert.Equal(t, 0, Metrics.PluginCacheHits["foo"])
+
+	n = 0
+	calls = newPluginCalls("bar", config.PluginConfig{Cache: true})
+	fn = calls.wrap("bar", f)
+	out, _ = fn("a", 1)
+	assert.Equal(t, 1, out)
+	out, _ = fn("a", 1)
+	assert.Equal(t, 1, out)
+	out, _ = fn("a", 2)
+	assert.Equal(t, 2, out)
+
+	// other functions of the same plugin are cached separately
+	out, _ = calls.wrap("baz", f)("a", 1)
+	assert.Equal(t, 3, out)
+
+	// readers can't be cached
+	out, _ = fn(strings.NewReader("a"))
+	assert.Equal(t, 4, out)
+	out, _ = fn(strings.NewReader("a"))
+	assert.Equal(t, 5, out)
+
+	assert.Equal(t, 5, Metrics.PluginCalls["bar"])
+	assert.Equal(t, 1, Metrics.PluginCacheHits["bar"])
+
+	// errors aren't cached, and timeouts are counted
+	fail := calls.wrap("fail", func(args ...interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("plugin timed out: %w", context.DeadlineExceeded)
+	})
+	_, err := fail()
+	assert.ErrorContains(t, err, "timed out")
+	_, err = fail()
+	assert.ErrorContains(t, err, "timed out")
+	assert.Equal(t, 7, Metrics.PluginCalls["bar"])
+	assert.Equal(t, 2, Metrics.PluginTimeouts["bar"])
+}
+
+func TestPluginCallsMaxConcurrency(t *testing.T) {
+	calls := newPluginCalls("foo", config.PluginConfig{MaxConcurrency: 2})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := calls.wrap("foo", func(args ...interface{}) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = fn()
+		}()
+	}
+
+	// only two calls start while they're blocked
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("more than 2 calls running at once")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// each call that finishes lets another start
+	for i := 0; i < 3; i++ {
+		release <- struct{}{}
+		<-started
+	}
+	close(release)
+	wg.Wait()
+}
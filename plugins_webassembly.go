@@ -0,0 +1,242 @@
+package gomplate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// Functions that WebAssembly plugins export to let gomplate manage their
+// memory. Only the allocator is required.
+const (
+	wasmAllocFunc = "gomplate_alloc"
+	wasmFreeFunc  = "gomplate_free"
+)
+
+// wasmPlugin - a plugin which is a WebAssembly module, run in-process with no
+// access to the host beyond the configured mounts. Each exported function
+// taking (ptr, len i32) and returning an i64 is bound as a template function.
+// Arguments are passed as a JSON array written to the module's memory, and the
+// function returns the location of a JSON response (ptr<<32 | len) in the same
+// form as a JSON-RPC response, with either a "result" or an "error".
+type wasmPlugin struct {
+	*plugin
+	mounts []config.PluginMount
+
+	// calls are made one at a time
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	mod      api.Module
+}
+
+func newWasmPlugin(plugin *plugin, mounts []config.PluginMount) (*wasmPlugin, error) {
+	b, err := ioutil.ReadFile(plugin.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin %s: %w", plugin.name, err)
+	}
+
+	// modules are closed when their context is done, so calls can time out
+	r := wazero.NewRuntimeWithConfig(plugin.ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	_, err = wasi_snapshot_preview1.Instantiate(plugin.ctx, r)
+	if err != nil {
+		_ = r.Close(plugin.ctx)
+		return nil, err
+	}
+
+	compiled, err := r.CompileModule(plugin.ctx, b)
+	if err != nil {
+		_ = r.Close(plugin.ctx)
+		return nil, fmt.Errorf("failed to compile plugin %s: %w", plugin.name, err)
+	}
+
+	p := &wasmPlugin{plugin: plugin, mounts: mounts, runtime: r, compiled: compiled}
+
+	alloc, ok := compiled.ExportedFunctions()[wasmAllocFunc]
+	if !ok || !hasSignature(alloc, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}) {
+		p.close()
+		return nil, fmt.Errorf("plugin %s must export %s(size i32) i32", plugin.name, wasmAllocFunc)
+	}
+	if _, ok := compiled.ExportedMemories()["memory"]; !ok {
+		p.close()
+		return nil, fmt.Errorf("plugin %s must export its memory", plugin.name)
+	}
+	return p, nil
+}
+
+func hasSignature(def api.FunctionDefinition, params, results []api.ValueType) bool {
+	return string(def.ParamTypes()) == string(params) && string(def.ResultTypes()) == string(results)
+}
+
+// functions - the names of the functions the module exports for templates
+func (p *wasmPlugin) functions() []string {
+	params := []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}
+	results := []api.ValueType{api.ValueTypeI64}
+
+	names := []string{}
+	for name, def := range p.compiled.ExportedFunctions() {
+		if strings.HasPrefix(name, "gomplate_") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if hasSignature(def, params, results) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *wasmPlugin) fn(name string) func(args ...interface{}) (interface{}, error) {
+	return func(args ...interface{}) (interface{}, error) {
+		return p.call(name, args...)
+	}
+}
+
+// call - call the exported function with the given arguments. The module is
+// instantiated on the first call.
+func (p *wasmPlugin) call(name string, args ...interface{}) (interface{}, error) {
+	return p.callWithTimeout(p.timeout, name, args...)
+}
+
+// callWithTimeout - call the exported function, overriding the plugin's
+// timeout
+func (p *wasmPlugin) callWithTimeout(timeout time.Duration, name string, args ...interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	in, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode arguments for %s: %w", name, err)
+	}
+
+	if p.mod == nil {
+		err = p.instantiate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	out, err := p.exchange(ctx, name, in)
+	if err != nil {
+		// the module's in an unknown state, so it'll be re-instantiated on
+		// the next call
+		_ = p.mod.Close(p.ctx)
+		p.mod = nil
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin timed out after %v: %w", time.Since(start), ctx.Err())
+		}
+		return nil, err
+	}
+
+	resp := rpcResponse{}
+	err = json.Unmarshal(out, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", name, err)
+	}
+	return resp.value(name)
+}
+
+// exchange - write the input to the module's memory, call the function, and
+// read its output
+func (p *wasmPlugin) exchange(ctx context.Context, name string, in []byte) ([]byte, error) {
+	res, err := p.mod.ExportedFunction(wasmAllocFunc).Call(ctx, uint64(len(in)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate memory for %s: %w", name, err)
+	}
+	inPtr := uint32(res[0])
+	if !p.mod.Memory().Write(inPtr, in) {
+		return nil, fmt.Errorf("failed to write arguments for %s: out of range", name)
+	}
+
+	res, err = p.mod.ExportedFunction(name).Call(ctx, uint64(inPtr), uint64(len(in)))
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	b, ok := p.mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("failed to read response from %s: out of range", name)
+	}
+	// the memory is about to be freed, and may be reused
+	out := make([]byte, len(b))
+	copy(out, b)
+
+	if free := p.mod.ExportedFunction(wasmFreeFunc); free != nil {
+		_, err = free.Call(ctx, uint64(inPtr), uint64(len(in)))
+		if err == nil {
+			_, err = free.Call(ctx, uint64(outPtr), uint64(outLen))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to free memory for %s: %w", name, err)
+		}
+	}
+	return out, nil
+}
+
+func (p *wasmPlugin) instantiate() error {
+	fsConfig := wazero.NewFSConfig()
+	for _, m := range p.mounts {
+		guest := m.Guest
+		if guest == "" {
+			guest = filepath.ToSlash(m.Host)
+		}
+		if m.ReadOnly {
+			fsConfig = fsConfig.WithReadOnlyDirMount(m.Host, guest)
+		} else {
+			fsConfig = fsConfig.WithDirMount(m.Host, guest)
+		}
+	}
+
+	// anything the module prints is treated as diagnostic output
+	var stderr io.Writer = ioutil.Discard
+	if p.stderr != nil {
+		stderr = p.stderr
+	}
+
+	modConfig := wazero.NewModuleConfig().
+		WithName(p.name).
+		WithStartFunctions("_initialize").
+		WithStdout(stderr).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader).
+		WithFSConfig(fsConfig)
+
+	mod, err := p.runtime.InstantiateModule(p.ctx, p.compiled, modConfig)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate plugin %s: %w", p.name, err)
+	}
+	p.mod = mod
+	return nil
+}
+
+// close - release the runtime, and all modules
+func (p *wasmPlugin) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_ = p.runtime.Close(p.ctx)
+	p.mod = nil
+}
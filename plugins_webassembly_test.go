@@ -0,0 +1,166 @@
+package gomplate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// testWasmModule - a hand-assembled module, equivalent to:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (global $next (mut i32) (i32.const 1024))
+//	  (data (i32.const 0) "{\"result\":")
+//	  (data (i32.const 16) "}")
+//	  (data (i32.const 32) "{\"error\":{\"code\":1,\"message\":\"boom\"}}")
+//	  ;; a bump allocator
+//	  (func $alloc (export "gomplate_alloc") (param $size i32) (result i32) ...)
+//	  ;; responds with the arguments: {"result":<args>}
+//	  (func (export "echo") (param $ptr i32) (param $len i32) (result i64) ...)
+//	  ;; responds with the error at offset 32
+//	  (func (export "fail") (param i32 i32) (result i64) ...)
+//	  ;; loops forever
+//	  (func (export "spin") (param i32 i32) (result i64) ...)
+//	  ;; not bound, since the signature doesn't match
+//	  (func (export "add") (param i32 i32) (result i32) ...))
+var testWasmModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x12, 0x03, 0x60,
+	0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x60, 0x02,
+	0x7f, 0x7f, 0x01, 0x7f, 0x03, 0x06, 0x05, 0x00, 0x01, 0x01, 0x01, 0x02,
+	0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x07, 0x01, 0x7f, 0x01, 0x41, 0x80,
+	0x08, 0x0b, 0x07, 0x36, 0x06, 0x06, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79,
+	0x02, 0x00, 0x0e, 0x67, 0x6f, 0x6d, 0x70, 0x6c, 0x61, 0x74, 0x65, 0x5f,
+	0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00, 0x04, 0x65, 0x63, 0x68, 0x6f,
+	0x00, 0x01, 0x04, 0x66, 0x61, 0x69, 0x6c, 0x00, 0x02, 0x04, 0x73, 0x70,
+	0x69, 0x6e, 0x00, 0x03, 0x03, 0x61, 0x64, 0x64, 0x00, 0x04, 0x0a, 0x70,
+	0x05, 0x11, 0x01, 0x01, 0x7f, 0x23, 0x00, 0x21, 0x01, 0x23, 0x00, 0x20,
+	0x00, 0x6a, 0x24, 0x00, 0x20, 0x01, 0x0b, 0x3f, 0x01, 0x01, 0x7f, 0x20,
+	0x01, 0x41, 0x0b, 0x6a, 0x10, 0x00, 0x21, 0x02, 0x20, 0x02, 0x41, 0x00,
+	0x41, 0x0a, 0xfc, 0x0a, 0x00, 0x00, 0x20, 0x02, 0x41, 0x0a, 0x6a, 0x20,
+	0x00, 0x20, 0x01, 0xfc, 0x0a, 0x00, 0x00, 0x20, 0x02, 0x41, 0x0a, 0x6a,
+	0x20, 0x01, 0x6a, 0x41, 0xfd, 0x00, 0x3a, 0x00, 0x00, 0x20, 0x02, 0xad,
+	0x42, 0x20, 0x86, 0x20, 0x01, 0x41, 0x0b, 0x6a, 0xad, 0x84, 0x0b, 0x0a,
+	0x00, 0x42, 0x20, 0x42, 0x20, 0x86, 0x42, 0x25, 0x84, 0x0b, 0x09, 0x00,
+	0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00, 0x0b, 0x07, 0x00, 0x20, 0x00,
+	0x20, 0x01, 0x6a, 0x0b, 0x0b, 0x40, 0x03, 0x00, 0x41, 0x00, 0x0b, 0x0a,
+	0x7b, 0x22, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x3a, 0x00, 0x41,
+	0x10, 0x0b, 0x01, 0x7d, 0x00, 0x41, 0x20, 0x0b, 0x25, 0x7b, 0x22, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x22, 0x3a, 0x7b, 0x22, 0x63, 0x6f, 0x64, 0x65,
+	0x22, 0x3a, 0x31, 0x2c, 0x22, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
+	0x22, 0x3a, 0x22, 0x62, 0x6f, 0x6f, 0x6d, 0x22, 0x7d, 0x7d,
+}
+
+func writeTestWasmModule(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.wasm")
+	err := ioutil.WriteFile(path, testWasmModule, 0600)
+	assert.NilError(t, err)
+	return path
+}
+
+func testWasmPlugin(t *testing.T, timeout time.Duration) *wasmPlugin {
+	t.Helper()
+	p, err := newWasmPlugin(&plugin{
+		ctx:     context.Background(),
+		name:    "test",
+		path:    writeTestWasmModule(t),
+		timeout: timeout,
+		stderr:  &bytes.Buffer{},
+	}, nil)
+	assert.NilError(t, err)
+	t.Cleanup(p.close)
+	return p
+}
+
+func TestWasmPluginFunctions(t *testing.T) {
+	p := testWasmPlugin(t, time.Second)
+	assert.DeepEqual(t, []string{"echo", "fail", "spin"}, p.functions())
+}
+
+func TestWasmPluginCall(t *testing.T) {
+	p := testWasmPlugin(t, time.Second)
+
+	out, err := p.call("echo", "hello", 42, map[string]interface{}{"k": []interface{}{true}})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"hello", 42.0, map[string]interface{}{"k": []interface{}{true}}}, out)
+
+	out, err = p.call("echo")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{}, out)
+
+	_, err = p.call("fail", "x")
+	assert.ErrorContains(t, err, "fail failed: boom (code 1)")
+
+	// the module is still usable after errors
+	out, err = p.fn("echo")("again")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"again"}, out)
+}
+
+func TestWasmPluginTimeout(t *testing.T) {
+	p := testWasmPlugin(t, 100*time.Millisecond)
+
+	_, err := p.call("spin")
+	assert.ErrorContains(t, err, "plugin timed out")
+
+	// the module is re-instantiated after timing out
+	out, err := p.call("echo", "hi")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []interface{}{"hi"}, out)
+}
+
+func TestNewWasmPluginErrors(t *testing.T) {
+	ctx := context.Background()
+	_, err := newWasmPlugin(&plugin{ctx: ctx, name: "test", path: "/no/such/file.wasm"}, nil)
+	assert.ErrorContains(t, err, "failed to read plugin test")
+
+	path := filepath.Join(t.TempDir(), "bad.wasm")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("not wasm"), 0600))
+	_, err = newWasmPlugin(&plugin{ctx: ctx, name: "test", path: path}, nil)
+	assert.ErrorContains(t, err, "failed to compile plugin test")
+
+	// an empty module doesn't export an allocator
+	assert.NilError(t, ioutil.WriteFile(path, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0600))
+	_, err = newWasmPlugin(&plugin{ctx: ctx, name: "test", path: path}, nil)
+	assert.ErrorContains(t, err, "must export gomplate_alloc")
+}
+
+func TestBindWasmPlugin(t *testing.T) {
+	fm := template.FuncMap{}
+	cfg := &config.Config{
+		Plugins: map[string]config.PluginConfig{
+			"test": {Cmd: writeTestWasmModule(t)},
+		},
+		PluginTimeout: time.Second,
+	}
+	err := bindPlugins(context.Background(), cfg, fm, nil)
+	assert.NilError(t, err)
+	defer runCleanupHooks()
+
+	assert.Check(t, cmp.Contains(fm, "echo"))
+	assert.Check(t, cmp.Contains(fm, "fail"))
+	// the plugin's name isn't bound, nor are functions with other signatures
+	_, ok := fm["test"]
+	assert.Check(t, !ok)
+	_, ok = fm["add"]
+	assert.Check(t, !ok)
+
+	tmpl, err := template.New("t").Funcs(fm).Parse(`{{ index (echo "a" "b") 1 }}`)
+	assert.NilError(t, err)
+	out := &bytes.Buffer{}
+	assert.NilError(t, tmpl.Execute(out, nil))
+	assert.Equal(t, "b", out.String())
+
+	fm = template.FuncMap{"echo": nil}
+	err = bindPlugins(context.Background(), cfg, fm, nil)
+	assert.ErrorContains(t, err, `function "echo" is already bound`)
+}
@@ -0,0 +1,99 @@
+package gomplate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// rewriteLink - rewrites a symlink's target that points into the input
+// directory so that it points to the corresponding output instead. Relative
+// targets stay relative, and targets outside the input directory are returned
+// unchanged.
+func rewriteLink(dir, file, target string, outFileNamer func(string) (string, error)) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := target
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(absDir, filepath.Dir(file), target)
+	}
+	rel, err := filepath.Rel(absDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return target, nil
+	}
+
+	outPath, err := outFileNamer(rel)
+	if err != nil {
+		return "", err
+	}
+	outPath, err = filepath.Abs(outPath)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(target) {
+		return outPath, nil
+	}
+
+	linkPath, err := outFileNamer(file)
+	if err != nil {
+		return "", err
+	}
+	linkPath, err = filepath.Abs(linkPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(filepath.Dir(linkPath), outPath)
+}
+
+// symlinkOutput - an output which is a symlink, rather than a regular file.
+// The link is created when the output is closed, unless it already exists with
+// the same target.
+type symlinkOutput struct {
+	path    string
+	target  string
+	changed bool
+}
+
+func (s *symlinkOutput) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("can't write to %s: output is a symlink", s.path)
+}
+
+func (s *symlinkOutput) Close() error {
+	fi, err := lstat(s.path)
+	if err == nil {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if current, err := readlink(s.path); err == nil && current == s.target {
+				return nil
+			}
+		} else if fi.IsDir() {
+			return isDirError(s.path)
+		}
+
+		err = fs.Remove(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to replace %s with a symlink: %w", s.path, err)
+		}
+	}
+
+	linker, ok := fs.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: s.target, New: s.path, Err: afero.ErrNoSymlink}
+	}
+	err = linker.SymlinkIfPossible(s.target, s.path)
+	if err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", s.path, err)
+	}
+	s.changed = true
+	return nil
+}
+
+// Changed - whether the link was (re)created
+func (s *symlinkOutput) Changed() bool {
+	return s.changed
+}
@@ -0,0 +1,158 @@
+package gomplate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteLink(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	in := filepath.Join(wd, "in")
+	out := filepath.Join(wd, "out")
+	renamer := func(p string) (string, error) {
+		return filepath.Join(out, "renamed", p+".out"), nil
+	}
+
+	testdata := []struct {
+		desc     string
+		file     string
+		target   string
+		namer    func(string) (string, error)
+		expected string
+	}{
+		{
+			desc:     "relative targets inside the input dir stay relative",
+			file:     filepath.Join("a", "link"),
+			target:   filepath.Join("..", "b", "file"),
+			expected: filepath.Join("..", "b", "file"),
+		},
+		{
+			desc:     "relative targets that leave and re-enter the input dir are cleaned",
+			file:     "link",
+			target:   filepath.Join("..", "in", "b", "file"),
+			expected: filepath.Join("b", "file"),
+		},
+		{
+			desc:     "absolute targets inside the input dir point to the output dir",
+			file:     "link",
+			target:   filepath.Join(in, "b", "file"),
+			expected: filepath.Join(out, "b", "file"),
+		},
+		{
+			desc:     "absolute targets outside the input dir are left alone",
+			file:     "link",
+			target:   filepath.Join(wd, "elsewhere", "file"),
+			expected: filepath.Join(wd, "elsewhere", "file"),
+		},
+		{
+			desc:     "relative targets escaping the input dir are left alone",
+			file:     "link",
+			target:   filepath.Join("..", "elsewhere"),
+			expected: filepath.Join("..", "elsewhere"),
+		},
+		{
+			desc:     "relative targets escaping the input dir from a subdirectory are left alone",
+			file:     filepath.Join("a", "b", "link"),
+			target:   filepath.Join("..", "..", "..", "elsewhere"),
+			expected: filepath.Join("..", "..", "..", "elsewhere"),
+		},
+		{
+			desc:     "relative targets escaping the input dir part way through are left alone",
+			file:     "link",
+			target:   filepath.Join("a", "..", "..", "elsewhere"),
+			expected: filepath.Join("a", "..", "..", "elsewhere"),
+		},
+		{
+			desc:     "relative targets to a sibling dir with the same prefix are left alone",
+			file:     "link",
+			target:   filepath.Join("..", "in2", "file"),
+			expected: filepath.Join("..", "in2", "file"),
+		},
+		{
+			desc:     "relative targets to renamed outputs are followed",
+			file:     "link",
+			target:   "file",
+			namer:    renamer,
+			expected: "file.out",
+		},
+		{
+			desc:     "absolute targets to renamed outputs are followed",
+			file:     "link",
+			target:   filepath.Join(in, "file"),
+			namer:    renamer,
+			expected: filepath.Join(out, "renamed", "file.out"),
+		},
+	}
+
+	for _, d := range testdata {
+		d := d
+		t.Run(d.desc, func(t *testing.T) {
+			namer := d.namer
+			if namer == nil {
+				namer = simpleNamer(out)
+			}
+			l, err := rewriteLink(in, d.file, d.target, namer)
+			assert.NoError(t, err)
+			assert.Equal(t, d.expected, l)
+		})
+	}
+}
+
+func TestSymlinkOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on Windows")
+	}
+
+	origfs := fs
+	defer func() { fs = origfs }()
+	fs = afero.NewOsFs()
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "link")
+
+	s := &symlinkOutput{path: link, target: "foo"}
+	_, err := s.Write([]byte("hello"))
+	assert.Error(t, err)
+
+	require.NoError(t, s.Close())
+	assert.True(t, s.Changed())
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", target)
+
+	// unchanged links are left alone
+	s = &symlinkOutput{path: link, target: "foo"}
+	require.NoError(t, s.Close())
+	assert.False(t, s.Changed())
+
+	s = &symlinkOutput{path: link, target: "bar"}
+	require.NoError(t, s.Close())
+	assert.True(t, s.Changed())
+	target, err = os.Readlink(link)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", target)
+
+	// regular files are replaced
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("hi"), 0600))
+	s = &symlinkOutput{path: file, target: "bar"}
+	require.NoError(t, s.Close())
+	fi, err := os.Lstat(file)
+	require.NoError(t, err)
+	assert.NotZero(t, fi.Mode()&os.ModeSymlink)
+
+	// directories aren't
+	s = &symlinkOutput{path: dir, target: "bar"}
+	assert.Error(t, s.Close())
+
+	fs = afero.NewMemMapFs()
+	s = &symlinkOutput{path: "/link", target: "foo"}
+	assert.Error(t, s.Close())
+}
@@ -0,0 +1,318 @@
+package gomplate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/spf13/afero"
+	"github.com/zealic/xignore"
+)
+
+// inputFile - a file found while walking the input directory
+type inputFile struct {
+	// the path, relative to the input directory
+	path string
+	// the symlink's target, when symlinks are preserved
+	link string
+}
+
+// inputWalker - lists the files in an input directory, handling symlinks
+// according to the configured mode
+type inputWalker struct {
+	root        string
+	excludeGlob []string
+	symlinks    string
+	// the real paths of the followed directories currently being walked, for
+	// loop detection
+	visiting map[string]bool
+	// for applying the exclude globs within followed directories
+	isExcluded func(string) bool
+	// matchers for the .gomplateignore files in the directories above
+	// followed directories, by directory (relative to the root)
+	ignores map[string]func(string) bool
+}
+
+// listInputFiles - walk the input directory and list the files which aren't
+// excluded by .gomplateignore files or the exclude globs
+func listInputFiles(dir string, excludeGlob []string, symlinks string) ([]inputFile, error) {
+	w := &inputWalker{
+		root:        dir,
+		excludeGlob: excludeGlob,
+		symlinks:    symlinks,
+		visiting:    map[string]bool{},
+		ignores:     map[string]func(string) bool{},
+	}
+
+	if symlinks == config.SymlinksFollow {
+		var err error
+		w.isExcluded, err = globMatcher(excludeGlob)
+		if err != nil {
+			return nil, err
+		}
+
+		real, err := realPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		w.visiting[real] = true
+	}
+
+	return w.walk("", dir)
+}
+
+// walk - list the files in basedir, which is found at sub (relative to the
+// root input directory)
+func (w *inputWalker) walk(sub, basedir string) ([]inputFile, error) {
+	// work around bug in xignore - a basedir of '.' doesn't work
+	if basedir == "." {
+		basedir, _ = os.Getwd()
+	}
+
+	// exclude globs are relative to the root, so within followed directories
+	// they're applied separately
+	opts := &xignore.MatchesOptions{
+		Ignorefile: gomplateignore,
+		Nested:     true, // allow nested ignorefile
+	}
+	if sub == "" {
+		opts.AfterPatterns = w.excludeGlob
+	}
+
+	matcherFs := fs
+	if w.symlinks != "" {
+		matcherFs = linkTolerantFs{fs}
+	}
+	matches, err := xignore.NewMatcher(matcherFs).Matches(basedir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("ignore matching failed for %s: %w", basedir, err)
+	}
+
+	files := make([]inputFile, 0, len(matches.UnmatchedFiles))
+	if w.symlinks == "" {
+		for _, f := range matches.UnmatchedFiles {
+			files = append(files, inputFile{path: f})
+		}
+		return files, nil
+	}
+
+	// symlinks to directories are only found amongst the directories. These
+	// are sorted in the order they were walked, so that the files found by
+	// following a link are listed in its place.
+	candidates := append([]string{}, matches.UnmatchedFiles...)
+	candidates = append(candidates, matches.UnmatchedDirs...)
+	sort.Slice(candidates, func(i, j int) bool {
+		return walkOrderLess(candidates[i], candidates[j])
+	})
+	dirs := make(map[string]bool, len(matches.UnmatchedDirs))
+	for _, d := range matches.UnmatchedDirs {
+		dirs[d] = true
+	}
+
+	for _, f := range candidates {
+		rel := filepath.Join(sub, f)
+		if sub != "" {
+			excluded, err := w.excluded(sub, rel)
+			if err != nil {
+				return nil, err
+			}
+			if excluded {
+				continue
+			}
+		}
+
+		inPath := filepath.Join(w.root, rel)
+		fi, err := lstat(inPath)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't stat %s: %w", inPath, err)
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			if !dirs[f] {
+				files = append(files, inputFile{path: rel})
+			}
+			continue
+		}
+
+		switch w.symlinks {
+		case config.SymlinksSkip:
+			continue
+		case config.SymlinksPreserve:
+			target, err := readlink(inPath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, inputFile{path: rel, link: target})
+		case config.SymlinksFollow:
+			followed, err := w.follow(rel, inPath)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, followed...)
+		}
+	}
+
+	return files, nil
+}
+
+// excluded - whether rel, found within the followed directory at sub, is
+// excluded. The matcher only reads the .gomplateignore files within the
+// followed directory, so the exclude globs, and the .gomplateignore files in
+// the directories above it, are applied here to the path through the link.
+func (w *inputWalker) excluded(sub, rel string) (bool, error) {
+	if w.isExcluded != nil && matchesPath(w.isExcluded, rel) {
+		return true, nil
+	}
+	for dir := filepath.Dir(sub); ; dir = filepath.Dir(dir) {
+		m, err := w.ignoreMatcher(dir)
+		if err != nil {
+			return false, err
+		}
+		p, err := filepath.Rel(dir, rel)
+		if err != nil {
+			return false, err
+		}
+		if m != nil && matchesPath(m, p) {
+			return true, nil
+		}
+		if dir == "." {
+			return false, nil
+		}
+	}
+}
+
+// matchesPath - whether the path, or any of the directories it's in, matches
+func matchesPath(match func(string) bool, p string) bool {
+	for ; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		if match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreMatcher - a matcher for the .gomplateignore file in dir (relative to
+// the root), or nil if there isn't one
+func (w *inputWalker) ignoreMatcher(dir string) (func(string) bool, error) {
+	if m, ok := w.ignores[dir]; ok {
+		return m, nil
+	}
+	f, err := fs.Open(filepath.Join(w.root, dir, gomplateignore))
+	if os.IsNotExist(err) {
+		w.ignores[dir] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ignorefile := &xignore.Ignorefile{}
+	err = ignorefile.FromReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(w.root, dir, gomplateignore), err)
+	}
+	m, err := globMatcher(ignorefile.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	w.ignores[dir] = m
+	return m, nil
+}
+
+// walkOrderLess - compares paths element by element, as they're ordered when
+// walking a directory tree
+func walkOrderLess(a, b string) bool {
+	ae := strings.Split(a, string(filepath.Separator))
+	be := strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(ae) && i < len(be); i++ {
+		if ae[i] != be[i] {
+			return ae[i] < be[i]
+		}
+	}
+	return len(ae) < len(be)
+}
+
+// follow - list the file(s) found by following the symlink at inPath
+func (w *inputWalker) follow(rel, inPath string) ([]inputFile, error) {
+	fi, err := fs.Stat(inPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't follow broken symlink %s: %w", inPath, err)
+	}
+	if !fi.IsDir() {
+		return []inputFile{{path: rel}}, nil
+	}
+
+	real, err := realPath(inPath)
+	if err != nil {
+		return nil, err
+	}
+	if w.visiting[real] {
+		return nil, fmt.Errorf("symlink loop detected: %s points to %s, which contains it", inPath, real)
+	}
+
+	w.visiting[real] = true
+	defer delete(w.visiting, real)
+
+	// the real path is walked, since the matcher won't descend into a symlink
+	return w.walk(rel, real)
+}
+
+// linkTolerantFs - reports broken symlinks as regular files, rather than
+// failing to stat them, so that the ignore matcher can list them
+type linkTolerantFs struct {
+	afero.Fs
+}
+
+func (l linkTolerantFs) Stat(name string) (os.FileInfo, error) {
+	fi, err := l.Fs.Stat(name)
+	if err != nil && os.IsNotExist(err) {
+		if lfi, lerr := lstatFs(l.Fs, name); lerr == nil && lfi.Mode()&os.ModeSymlink != 0 {
+			return lfi, nil
+		}
+	}
+	return fi, err
+}
+
+func (l linkTolerantFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if lstater, ok := l.Fs.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+	fi, err := l.Fs.Stat(name)
+	return fi, false, err
+}
+
+func lstat(name string) (os.FileInfo, error) {
+	return lstatFs(fs, name)
+}
+
+func lstatFs(f afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := f.(afero.Lstater); ok {
+		fi, _, err := lstater.LstatIfPossible(name)
+		return fi, err
+	}
+	return f.Stat(name)
+}
+
+func readlink(name string) (string, error) {
+	reader, ok := fs.(afero.LinkReader)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+	}
+	target, err := reader.ReadlinkIfPossible(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read symlink %s: %w", name, err)
+	}
+	return target, nil
+}
+
+// realPath - the absolute path of the named file, with all symlinks resolved
+func realPath(name string) (string, error) {
+	p, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	return filepath.Abs(p)
+}
@@ -0,0 +1,103 @@
+package gomplate
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkOrderLess(t *testing.T) {
+	assert.True(t, walkOrderLess("a", "b"))
+	assert.False(t, walkOrderLess("b", "a"))
+	assert.True(t, walkOrderLess("a", filepath.Join("a", "b")))
+	// directory contents are walked before siblings with longer names
+	assert.True(t, walkOrderLess(filepath.Join("a", "z"), "a.txt"))
+	assert.False(t, walkOrderLess("a.txt", filepath.Join("a", "z")))
+}
+
+func TestListInputFilesFollowLoops(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires extra privileges on Windows")
+	}
+
+	origfs := fs
+	defer func() { fs = origfs }()
+	fs = afero.NewOsFs()
+
+	testdata := []struct {
+		desc string
+		// links, relative to the parent of the input dir
+		links    map[string]string
+		expected []string
+		err      string
+	}{
+		{
+			desc:     "a link to a file",
+			links:    map[string]string{"in/link": "a/file"},
+			expected: []string{"a/file", "link"},
+		},
+		{
+			desc:     "links to the same directory aren't loops",
+			links:    map[string]string{"in/l1": "a", "in/l2": "a"},
+			expected: []string{"a/file", "l1/file", "l2/file"},
+		},
+		{
+			desc:  "a link to the input dir",
+			links: map[string]string{"in/loop": "."},
+			err:   "symlink loop detected",
+		},
+		{
+			desc:  "a link to a parent directory",
+			links: map[string]string{"in/a/up": ".."},
+			err:   "symlink loop detected",
+		},
+		{
+			desc:  "links between sibling directories",
+			links: map[string]string{"in/a/tob": "../b", "in/b/toa": "../a"},
+			err:   "symlink loop detected",
+		},
+		{
+			desc:  "a link out of the input dir, and back in",
+			links: map[string]string{"in/out": "../outside", "outside/back": "../in"},
+			err:   "symlink loop detected",
+		},
+		{
+			desc:  "a broken link",
+			links: map[string]string{"in/broken": "nowhere"},
+			err:   "can't follow broken symlink",
+		},
+	}
+
+	for _, d := range testdata {
+		d := d
+		t.Run(d.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, sub := range []string{"in/a", "in/b", "outside"} {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, sub), 0755))
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "in", "a", "file"), []byte("hi"), 0600))
+			for link, target := range d.links {
+				require.NoError(t, os.Symlink(target, filepath.Join(dir, link)))
+			}
+
+			files, err := listInputFiles(filepath.Join(dir, "in"), nil, config.SymlinksFollow)
+			if d.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), d.err)
+				return
+			}
+			require.NoError(t, err)
+			paths := make([]string, len(files))
+			for i, f := range files {
+				paths[i] = filepath.ToSlash(f.path)
+			}
+			assert.Equal(t, d.expected, paths)
+		})
+	}
+}
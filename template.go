@@ -1,6 +1,7 @@
 package gomplate
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/spf13/afero"
 	"github.com/zealic/xignore"
+	"golang.org/x/text/transform"
 )
 
 // ignorefile name, like .gitignore
@@ -30,6 +32,10 @@ type tplate struct {
 	contents     string
 	mode         os.FileMode
 	modeOverride bool
+	// copy the input verbatim, rather than rendering it as a template
+	copy bool
+	// the target of the symlink to create, when symlinks are preserved
+	linkTarget string
 }
 
 func addTmplFuncs(f template.FuncMap, root *template.Template, ctx interface{}) {
@@ -69,6 +75,23 @@ func (t *tplate) toGoTemplate(g *gomplate) (tmpl *template.Template, err error)
 	return tmpl, nil
 }
 
+// copyContents - copies the input file verbatim to the target, for inputs that
+// aren't templates
+func (t *tplate) copyContents() error {
+	f, err := fs.OpenFile(t.name, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", t.name, err)
+	}
+	// nolint: errcheck
+	defer f.Close()
+
+	_, err = io.Copy(t.target, f)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", t.name, t.targetPath, err)
+	}
+	return nil
+}
+
 // loadContents - reads the template
 func (t *tplate) loadContents(in io.Reader) ([]byte, error) {
 	if in == nil {
@@ -109,7 +132,7 @@ func gatherTemplates(cfg *config.Config, outFileNamer func(string) (string, erro
 		}}
 	case cfg.InputDir != "":
 		// input dirs presume output dirs are set too
-		templates, err = walkDir(cfg.InputDir, outFileNamer, cfg.ExcludeGlob, mode, modeOverride)
+		templates, err = walkDir(cfg, cfg.InputDir, outFileNamer, cfg.ExcludeGlob, mode, modeOverride)
 		if err != nil {
 			return nil, err
 		}
@@ -130,6 +153,29 @@ func gatherTemplates(cfg *config.Config, outFileNamer func(string) (string, erro
 // outputs for writing as necessary
 func processTemplates(cfg *config.Config, templates []*tplate) ([]*tplate, error) {
 	for _, t := range templates {
+		if t.linkTarget != "" {
+			if t.target == nil {
+				t.target = &symlinkOutput{path: t.targetPath, target: t.linkTarget}
+			}
+			continue
+		}
+
+		if t.copy {
+			// copied files are streamed when rendering, and don't go through
+			// any of the output rules' transformations
+			if t.target == nil {
+				owner, err := outputOwner(cfg.OutputRuleFor(t.targetPath), t.name)
+				if err != nil {
+					return nil, err
+				}
+				t.target, err = createOutFile(t.targetPath, t.mode, t.modeOverride, owner)
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
 		if t.contents == "" {
 			var in io.Reader
 			if t.name == "-" {
@@ -141,11 +187,20 @@ func processTemplates(cfg *config.Config, templates []*tplate) ([]*tplate, error
 				return nil, err
 			}
 
+			if cfg.OutputRuleFor(t.targetPath).StripBOM {
+				b = iohelpers.StripBOM(b)
+			}
+
 			t.contents = string(b)
 		}
 
 		if t.target == nil {
-			out, err := openOutFile(cfg, t.targetPath, t.mode, t.modeOverride)
+			owner, err := outputOwner(cfg.OutputRuleFor(t.targetPath), t.name)
+			if err != nil {
+				return nil, err
+			}
+
+			out, err := openOutFile(cfg, t.targetPath, t.mode, t.modeOverride, owner)
 			if err != nil {
 				return nil, err
 			}
@@ -159,8 +214,9 @@ func processTemplates(cfg *config.Config, templates []*tplate) ([]*tplate, error
 
 // walkDir - given an input dir `dir` and an output dir `outDir`, and a list
 // of .gomplateignore and exclude globs (if any), walk the input directory and create a list of
-// tplate objects, and an error, if any.
-func walkDir(dir string, outFileNamer func(string) (string, error), excludeGlob []string, mode os.FileMode, modeOverride bool) ([]*tplate, error) {
+// tplate objects, and an error, if any. Output directories are created as
+// needed, with the mode and owner configured for the outputs within them.
+func walkDir(cfg *config.Config, dir string, outFileNamer func(string) (string, error), excludeGlob []string, mode os.FileMode, modeOverride bool) ([]*tplate, error) {
 	dir = filepath.Clean(dir)
 
 	dirStat, err := fs.Stat(dir)
@@ -169,34 +225,37 @@ func walkDir(dir string, outFileNamer func(string) (string, error), excludeGlob
 	}
 	dirMode := dirStat.Mode()
 
-	templates := make([]*tplate, 0)
-	matcher := xignore.NewMatcher(fs)
-
-	// work around bug in xignore - a basedir of '.' doesn't work
-	basedir := dir
-	if basedir == "." {
-		basedir, _ = os.Getwd()
+	files, err := listInputFiles(dir, excludeGlob, cfg.Symlinks)
+	if err != nil {
+		return nil, err
 	}
-	matches, err := matcher.Matches(basedir, &xignore.MatchesOptions{
-		Ignorefile:    gomplateignore,
-		Nested:        true, // allow nested ignorefile
-		AfterPatterns: excludeGlob,
-	})
+
+	isCopy, err := globMatcher(cfg.CopyGlob)
 	if err != nil {
-		return nil, fmt.Errorf("ignore matching failed for %s: %w", basedir, err)
+		return nil, fmt.Errorf("invalid copy glob: %w", err)
 	}
 
-	// Unmatched ignorefile rules's files
-	files := matches.UnmatchedFiles
-	for _, file := range files {
+	templates := make([]*tplate, 0, len(files))
+	for _, f := range files {
+		file := f.path
 		nextInPath := filepath.Join(dir, file)
 		nextOutPath, err := outFileNamer(file)
 		if err != nil {
 			return nil, err
 		}
 
+		link := f.link
+		if link != "" && cfg.RewriteSymlinks {
+			link, err = rewriteLink(dir, file, link, outFileNamer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// copied files always keep the input's mode
+		copyFile := link == "" && isCopy(file)
 		fMode := mode
-		if mode == 0 {
+		if link == "" && (mode == 0 || copyFile) {
 			stat, perr := fs.Stat(nextInPath)
 			if perr == nil {
 				fMode = stat.Mode()
@@ -206,7 +265,25 @@ func walkDir(dir string, outFileNamer func(string) (string, error), excludeGlob
 		}
 
 		// Ensure file parent dirs
-		if err = fs.MkdirAll(filepath.Dir(nextOutPath), dirMode); err != nil {
+		rule := cfg.OutputRuleFor(nextOutPath)
+		dMode, dModeOverride, err := rule.GetDirMode()
+		if err != nil {
+			return nil, err
+		}
+		if !dModeOverride {
+			dMode = dirMode
+		}
+		// preserved symlinks may be broken, so directories inherit their
+		// owner from the link's parent instead
+		ownerPath := nextInPath
+		if link != "" {
+			ownerPath = filepath.Dir(nextInPath)
+		}
+		owner, err := outputOwner(rule, ownerPath)
+		if err != nil {
+			return nil, err
+		}
+		if err = mkdirAll(filepath.Dir(nextOutPath), dMode, dModeOverride, owner); err != nil {
 			return nil, err
 		}
 
@@ -215,12 +292,43 @@ func walkDir(dir string, outFileNamer func(string) (string, error), excludeGlob
 			targetPath:   nextOutPath,
 			mode:         fMode,
 			modeOverride: modeOverride,
+			copy:         copyFile,
+			linkTarget:   link,
 		})
 	}
 
 	return templates, nil
 }
 
+// globMatcher - returns a function reporting whether the given path (relative
+// to the input directory) matches the globs - used to find files that should
+// be copied rather than rendered, for example. Globs use the same syntax as
+// .gomplateignore files, and later globs take precedence, so '!' can be used
+// to negate an earlier match.
+func globMatcher(globs []string) (func(string) bool, error) {
+	patterns := make([]*xignore.Pattern, 0, len(globs))
+	for _, g := range globs {
+		p := xignore.NewPattern(g)
+		if p.IsEmpty() {
+			continue
+		}
+		if err := p.Prepare(); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", g, err)
+		}
+		patterns = append(patterns, p)
+	}
+
+	return func(name string) bool {
+		matched := false
+		for _, p := range patterns {
+			if p.Match(name) {
+				matched = !p.IsExclusion()
+			}
+		}
+		return matched
+	}, nil
+}
+
 func fileToTemplates(inFile, outFile string, mode os.FileMode, modeOverride bool) (*tplate, error) {
 	if inFile != "-" {
 		si, err := fs.Stat(inFile)
@@ -241,24 +349,62 @@ func fileToTemplates(inFile, outFile string, mode os.FileMode, modeOverride bool
 	return tmpl, nil
 }
 
-func openOutFile(cfg *config.Config, filename string, mode os.FileMode, modeOverride bool) (out io.Writer, err error) {
+func openOutFile(cfg *config.Config, filename string, mode os.FileMode, modeOverride bool, owner *fileOwner) (out io.Writer, err error) {
+	rule := cfg.OutputRuleFor(filename)
+	open := func() (io.Writer, error) {
+		if filename == "-" {
+			// stdout (or its stand-in) is never closed along with the output
+			return wrapOutput(&iohelpers.NopCloser{Writer: cfg.Stdout}, rule)
+		}
+		f, err := createOutFile(filename, mode, modeOverride, owner)
+		if err != nil {
+			return nil, err
+		}
+		return wrapOutput(f, rule)
+	}
+
+	if process := outputProcessor(filename, rule); process != nil {
+		// buffer the output so that nothing is written if it's invalid
+		openRaw := open
+		open = func() (io.Writer, error) {
+			return iohelpers.ProcessingWriter(openRaw, process), nil
+		}
+	}
+
 	if cfg.SuppressEmpty {
-		out = iohelpers.NewEmptySkipper(func() (io.Writer, error) {
-			if filename == "-" {
-				return cfg.Stdout, nil
-			}
-			return createOutFile(filename, mode, modeOverride)
-		})
+		out = iohelpers.NewEmptySkipper(open)
 		return out, nil
 	}
 
-	if filename == "-" {
-		return cfg.Stdout, nil
+	return open()
+}
+
+// wrapOutput - wraps the writer to normalise line endings and/or transcode
+// the output, as configured by the rule. The writer is returned unchanged when
+// no transformations are needed.
+func wrapOutput(w io.Writer, rule config.OutputRule) (io.Writer, error) {
+	transformers := []transform.Transformer{}
+	if rule.LineEndings != "" {
+		t, err := iohelpers.LineEndings(rule.LineEndings)
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, t)
 	}
-	return createOutFile(filename, mode, modeOverride)
+	if rule.Charset != "" {
+		t, err := iohelpers.CharsetEncoder(rule.Charset)
+		if err != nil {
+			return nil, err
+		}
+		transformers = append(transformers, t)
+	}
+	if len(transformers) == 0 {
+		return w, nil
+	}
+	return iohelpers.TransformWriter(w, transform.Chain(transformers...)), nil
 }
 
-func createOutFile(filename string, mode os.FileMode, modeOverride bool) (out io.WriteCloser, err error) {
+func createOutFile(filename string, mode os.FileMode, modeOverride bool, owner *fileOwner) (out io.WriteCloser, err error) {
 	mode = iohelpers.NormalizeFileMode(mode.Perm())
 	if modeOverride {
 		err = fs.Chmod(filename, mode)
@@ -266,6 +412,10 @@ func createOutFile(filename string, mode os.FileMode, modeOverride bool) (out io
 			return nil, fmt.Errorf("failed to chmod output file '%s' with mode %q: %w", filename, mode, err)
 		}
 	}
+	// like the mode, ownership is applied even when the content's unchanged
+	if err = owner.chown(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
 
 	open := func() (out io.WriteCloser, err error) {
 		out, err = fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
@@ -273,7 +423,11 @@ func createOutFile(filename string, mode os.FileMode, modeOverride bool) (out io
 			return out, fmt.Errorf("failed to open output file '%s' for writing: %w", filename, err)
 		}
 
-		return out, err
+		if err = owner.chown(filename); err != nil {
+			out.Close()
+			return nil, err
+		}
+		return out, nil
 	}
 
 	// if the output file already exists, we'll use a SameSkipper
@@ -2,9 +2,11 @@ package gomplate
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hairyhenderson/gomplate/v3/internal/config"
@@ -22,7 +24,7 @@ func TestOpenOutFile(t *testing.T) {
 	_ = fs.Mkdir("/tmp", 0777)
 
 	cfg := &config.Config{}
-	f, err := openOutFile(cfg, "/tmp/foo", 0644, false)
+	f, err := openOutFile(cfg, "/tmp/foo", 0644, false, nil)
 	assert.NoError(t, err)
 
 	wc, ok := f.(io.WriteCloser)
@@ -36,9 +38,46 @@ func TestOpenOutFile(t *testing.T) {
 
 	cfg.Stdout = &bytes.Buffer{}
 
-	f, err = openOutFile(cfg, "-", 0644, false)
+	f, err = openOutFile(cfg, "-", 0644, false, nil)
 	assert.NoError(t, err)
-	assert.Equal(t, cfg.Stdout, f)
+	assert.Equal(t, &iohelpers.NopCloser{Writer: cfg.Stdout}, f)
+
+	// stdout is left open, even when it's a closer, and the output's wrapped
+	stdout := &closeRecorder{}
+	cfg.Stdout = stdout
+	cfg.OutputRules = []config.OutputRule{{Glob: "-", LineEndings: "crlf"}}
+	f, err = openOutFile(cfg, "-", 0644, false, nil)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.(io.Closer).Close())
+	assert.Equal(t, "hello\r\n", stdout.String())
+	assert.False(t, stdout.closed)
+}
+
+func TestOpenOutFileValidatedStdout(t *testing.T) {
+	stdout := &closeRecorder{}
+	cfg := &config.Config{
+		Stdout:      stdout,
+		OutputRules: []config.OutputRule{{Glob: "*", Validate: "json"}},
+	}
+	f, err := openOutFile(cfg, "-", 0644, false, nil)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte(`{"foo": "bar"}`))
+	assert.NoError(t, err)
+	assert.NoError(t, f.(io.Closer).Close())
+	assert.Equal(t, `{"foo": "bar"}`, stdout.String())
+	assert.False(t, stdout.closed)
+}
+
+type closeRecorder struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
 }
 
 func TestLoadContents(t *testing.T) {
@@ -82,7 +121,7 @@ func TestGatherTemplates(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, templates, 1)
 	assert.Equal(t, "foo", templates[0].contents)
-	assert.Equal(t, cfg.Stdout, templates[0].target)
+	assert.Equal(t, &iohelpers.NopCloser{Writer: cfg.Stdout}, templates[0].target)
 
 	templates, err = gatherTemplates(&config.Config{
 		Input:       "foo",
@@ -183,7 +222,7 @@ func TestProcessTemplates(t *testing.T) {
 			templates: []*tplate{{name: "<arg>", contents: "foo", targetPath: "-", mode: 0644}},
 			contents:  []string{"foo"},
 			modes:     []os.FileMode{0644},
-			targets:   []io.Writer{cfg.Stdout},
+			targets:   []io.Writer{&iohelpers.NopCloser{Writer: cfg.Stdout}},
 		},
 		{
 			templates: []*tplate{{name: "<arg>", contents: "foo", targetPath: "out", mode: 0644}},
@@ -255,13 +294,97 @@ func TestProcessTemplates(t *testing.T) {
 	}
 }
 
+func TestProcessTemplatesEncoding(t *testing.T) {
+	origfs := fs
+	defer func() { fs = origfs }()
+	fs = afero.NewMemMapFs()
+	afero.WriteFile(fs, "in.tmpl", []byte("\xEF\xBB\xBFhello\r\nworld\r\n"), 0644)
+
+	cfg := &config.Config{
+		Stdout:      &bytes.Buffer{},
+		LineEndings: "lf",
+		OutputRules: []config.OutputRule{
+			{Glob: "*.txt", StripBOM: true, Charset: "latin1"},
+		},
+	}
+
+	templates, err := processTemplates(cfg, []*tplate{
+		{name: "in.tmpl", targetPath: "out.txt", mode: 0644},
+		{name: "in.tmpl", targetPath: "out.raw", mode: 0644},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\r\nworld\r\n", templates[0].contents)
+	assert.Equal(t, "\xEF\xBB\xBFhello\r\nworld\r\n", templates[1].contents)
+
+	for _, tp := range templates {
+		_, err = tp.target.Write([]byte(tp.contents + "café\r\n"))
+		require.NoError(t, err)
+		require.NoError(t, tp.target.(io.Closer).Close())
+	}
+
+	b, err := afero.ReadFile(fs, "out.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\nworld\ncaf\xe9\n", string(b))
+
+	b, err = afero.ReadFile(fs, "out.raw")
+	assert.NoError(t, err)
+	assert.Equal(t, "\xEF\xBB\xBFhello\nworld\ncafé\n", string(b))
+}
+
+func TestProcessTemplatesCopy(t *testing.T) {
+	origfs := fs
+	defer func() { fs = origfs }()
+	fs = afero.NewMemMapFs()
+	in := "\xEF\xBB\xBF{{ not a template }}\r\n\x00\xff"
+	afero.WriteFile(fs, "in/logo.bin", []byte(in), 0755)
+
+	// output rules don't apply to copied files
+	cfg := &config.Config{
+		StripBOM:    true,
+		LineEndings: "lf",
+	}
+
+	templates, err := processTemplates(cfg, []*tplate{
+		{name: "in/logo.bin", targetPath: "out.bin", mode: 0755, copy: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", templates[0].contents)
+
+	g := &gomplate{}
+	err = g.runTemplate(context.Background(), templates[0])
+	require.NoError(t, err)
+
+	b, err := afero.ReadFile(fs, "out.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, in, string(b))
+
+	fi, err := fs.Stat("out.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, iohelpers.NormalizeFileMode(0755), fi.Mode())
+}
+
+func TestGlobMatcher(t *testing.T) {
+	isCopy, err := globMatcher(nil)
+	require.NoError(t, err)
+	assert.False(t, isCopy("foo.png"))
+
+	isCopy, err = globMatcher([]string{"*.png", "static/**", "!static/*.tmpl", "", "bin/**"})
+	require.NoError(t, err)
+	assert.True(t, isCopy("foo.png"))
+	assert.True(t, isCopy(filepath.Join("img", "foo.png")))
+	assert.False(t, isCopy("foo.txt"))
+	assert.True(t, isCopy(filepath.Join("bin", "sub", "tool")))
+	assert.True(t, isCopy(filepath.Join("static", "style.css")))
+	assert.False(t, isCopy(filepath.Join("static", "index.tmpl")))
+}
+
 func TestCreateOutFile(t *testing.T) {
 	origfs := fs
 	defer func() { fs = origfs }()
 	fs = afero.NewMemMapFs()
 	_ = fs.Mkdir("in", 0755)
 
-	_, err := createOutFile("in", 0644, false)
+	_, err := createOutFile("in", 0644, false, nil)
 	assert.Error(t, err)
 	assert.IsType(t, &os.PathError{}, err)
 }
@@ -5,6 +5,7 @@ package gomplate
 
 import (
 	"os"
+	"syscall"
 
 	"golang.org/x/sys/unix"
 )
@@ -16,3 +17,12 @@ func isDirError(name string) *os.PathError {
 		Err:  unix.EISDIR,
 	}
 }
+
+// fileOwnerIDs - the user and group IDs that own the file, if available
+func fileOwnerIDs(fi os.FileInfo) (uid, gid int, ok bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+	return int(st.Uid), int(st.Gid), true
+}
@@ -4,8 +4,10 @@
 package gomplate
 
 import (
+	"os"
 	"testing"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
 	"github.com/spf13/afero"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +18,7 @@ func TestWalkDir(t *testing.T) {
 	defer func() { fs = origfs }()
 	fs = afero.NewMemMapFs()
 
-	_, err := walkDir("/indir", simpleNamer("/outdir"), nil, 0, false)
+	_, err := walkDir(&config.Config{}, "/indir", simpleNamer("/outdir"), nil, 0, false)
 	assert.Error(t, err)
 
 	_ = fs.MkdirAll("/indir/one", 0777)
@@ -25,7 +27,7 @@ func TestWalkDir(t *testing.T) {
 	afero.WriteFile(fs, "/indir/one/bar", []byte("bar"), 0664)
 	afero.WriteFile(fs, "/indir/two/baz", []byte("baz"), 0644)
 
-	templates, err := walkDir("/indir", simpleNamer("/outdir"), []string{"*/two"}, 0, false)
+	templates, err := walkDir(&config.Config{}, "/indir", simpleNamer("/outdir"), []string{"*/two"}, 0, false)
 
 	assert.NoError(t, err)
 	expected := []*tplate{
@@ -41,4 +43,45 @@ func TestWalkDir(t *testing.T) {
 		},
 	}
 	assert.EqualValues(t, expected, templates)
+
+	fi, err := fs.Stat("/outdir/one")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0777), fi.Mode().Perm())
+
+	// directory modes can be set per-output
+	cfg := &config.Config{
+		DirMode:     "0750",
+		OutputRules: []config.OutputRule{{Glob: "/outdir2/two/*", DirMode: "0700"}},
+	}
+	_, err = walkDir(cfg, "/indir", simpleNamer("/outdir2"), nil, 0, false)
+	assert.NoError(t, err)
+
+	fi, err = fs.Stat("/outdir2/one")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), fi.Mode().Perm())
+
+	fi, err = fs.Stat("/outdir2/two")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	// copied files keep the input's mode, even when it's overridden
+	cfg = &config.Config{CopyGlob: []string{"bar"}}
+	templates, err = walkDir(cfg, "/indir", simpleNamer("/outdir"), []string{"*/two"}, 0600, true)
+	assert.NoError(t, err)
+	expected = []*tplate{
+		{
+			name:         "/indir/one/bar",
+			targetPath:   "/outdir/one/bar",
+			mode:         0664,
+			modeOverride: true,
+			copy:         true,
+		},
+		{
+			name:         "/indir/one/foo",
+			targetPath:   "/outdir/one/foo",
+			mode:         0600,
+			modeOverride: true,
+		},
+	}
+	assert.EqualValues(t, expected, templates)
 }
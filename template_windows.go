@@ -16,3 +16,9 @@ func isDirError(name string) *os.PathError {
 		Err:  windows.ERROR_INVALID_HANDLE,
 	}
 }
+
+// fileOwnerIDs - file ownership isn't expressed as user and group IDs on
+// Windows, so is never available, and inheriting it does nothing
+func fileOwnerIDs(fi os.FileInfo) (uid, gid int, ok bool) {
+	return -1, -1, false
+}
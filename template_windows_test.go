@@ -6,6 +6,7 @@ package gomplate
 import (
 	"testing"
 
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
 	"github.com/spf13/afero"
 
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ func TestWalkDir(t *testing.T) {
 	defer func() { fs = origfs }()
 	fs = afero.NewMemMapFs()
 
-	_, err := walkDir(`C:\indir`, simpleNamer(`C:\outdir`), nil, 0, false)
+	_, err := walkDir(&config.Config{}, `C:\indir`, simpleNamer(`C:\outdir`), nil, 0, false)
 	assert.Error(t, err)
 
 	_ = fs.MkdirAll(`C:\indir\one`, 0777)
@@ -25,7 +26,7 @@ func TestWalkDir(t *testing.T) {
 	afero.WriteFile(fs, `C:\indir\one\bar`, []byte("bar"), 0644)
 	afero.WriteFile(fs, `C:\indir\two\baz`, []byte("baz"), 0644)
 
-	templates, err := walkDir(`C:\indir`, simpleNamer(`C:\outdir`), []string{`*\two`}, 0, false)
+	templates, err := walkDir(&config.Config{}, `C:\indir`, simpleNamer(`C:\outdir`), []string{`*\two`}, 0, false)
 
 	assert.NoError(t, err)
 	expected := []*tplate{
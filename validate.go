@@ -0,0 +1,123 @@
+package gomplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hairyhenderson/gomplate/v3/data"
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// outputProcessor - returns a function that validates (and optionally
+// reformats) rendered output as configured by the rule, or nil if the rule
+// doesn't call for validation
+func outputProcessor(outPath string, rule config.OutputRule) func([]byte) ([]byte, error) {
+	if rule.Validate == "" {
+		return nil
+	}
+	return func(b []byte) ([]byte, error) {
+		out, err := validateOutput(rule.Validate, rule.Format, b)
+		if err != nil {
+			return nil, fmt.Errorf("output %s is not valid %s: %w", outPath, rule.Validate, err)
+		}
+		return out, nil
+	}
+}
+
+func validateOutput(format string, reformat bool, b []byte) ([]byte, error) {
+	switch format {
+	case "json":
+		return validateJSON(b, reformat)
+	case "yaml":
+		return validateYAML(b, reformat)
+	case "toml":
+		return validateTOML(b, reformat)
+	default:
+		return nil, fmt.Errorf("unsupported validation format %q", format)
+	}
+}
+
+// validateJSON - parses the JSON with the same parser as JSON datasources,
+// and pretty-prints it with sorted keys if requested
+func validateJSON(b []byte, reformat bool) ([]byte, error) {
+	in := string(b)
+	var v interface{}
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(in), "[") {
+		v, err = data.JSONArray(in)
+	} else {
+		v, err = data.JSON(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !reformat {
+		return b, nil
+	}
+
+	out := &bytes.Buffer{}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	// encoding/json always sorts map keys
+	err = enc.Encode(v)
+	return out.Bytes(), err
+}
+
+// validateYAML - parses each document in the YAML stream with the same
+// parsers as YAML datasources, and re-encodes them if requested
+func validateYAML(b []byte, reformat bool) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	out := []string{}
+	for {
+		n := &yaml.Node{}
+		err := dec.Decode(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc, err := yaml.Marshal(n)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		if len(n.Content) > 0 && n.Content[0].Kind == yaml.SequenceNode {
+			v, err = data.YAMLArray(string(doc))
+		} else {
+			v, err = data.YAML(string(doc))
+		}
+		if err != nil {
+			return nil, err
+		}
+		if reformat {
+			s, err := data.ToYAML(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, s)
+		}
+	}
+	if !reformat {
+		return b, nil
+	}
+	return []byte(strings.Join(out, "---\n")), nil
+}
+
+func validateTOML(b []byte, reformat bool) ([]byte, error) {
+	v, err := data.TOML(string(b))
+	if err != nil {
+		return nil, err
+	}
+	if !reformat {
+		return b, nil
+	}
+	s, err := data.ToTOML(v)
+	return []byte(s), err
+}
@@ -0,0 +1,54 @@
+package gomplate
+
+import (
+	"testing"
+
+	"github.com/hairyhenderson/gomplate/v3/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateOutput(t *testing.T) {
+	testdata := []struct {
+		format   string
+		reformat bool
+		in, out  string
+	}{
+		{"json", false, `{"b": 1, "a": [1, 2]}`, `{"b": 1, "a": [1, 2]}`},
+		{"json", true, `{"b": 1, "a": [1, 2], "c": "<&>"}`, "{\n  \"a\": [\n    1,\n    2\n  ],\n  \"b\": 1,\n  \"c\": \"<&>\"\n}\n"},
+		{"json", true, `[12345678901234567890]`, "[\n  12345678901234567890\n]\n"},
+		{"yaml", false, "foo: bar\n---\nbaz: qux\n", "foo: bar\n---\nbaz: qux\n"},
+		{"yaml", true, "b:   1\na:   [1, 2]\n---\n- x\n", "a:\n  - 1\n  - 2\nb: 1\n---\n- x\n"},
+		{"toml", false, "foo = \"bar\"\n", "foo = \"bar\"\n"},
+		{"toml", true, "foo   =   \"bar\"\n", "foo = \"bar\"\n"},
+	}
+	for _, d := range testdata {
+		out, err := validateOutput(d.format, d.reformat, []byte(d.in))
+		assert.NoError(t, err)
+		assert.Equal(t, d.out, string(out))
+	}
+
+	invalid := []struct {
+		format, in string
+	}{
+		{"json", `{"foo": "bar"`},
+		{"json", `[1, 2`},
+		{"json", `"foo"`},
+		{"yaml", "foo: bar\n---\n\tbaz: [\n"},
+		{"yaml", "foo: bar\n---\njust a string\n"},
+		{"toml", "foo = \n"},
+		{"xml", "<foo/>"},
+	}
+	for _, d := range invalid {
+		_, err := validateOutput(d.format, false, []byte(d.in))
+		assert.Error(t, err, "%s: %s", d.format, d.in)
+	}
+}
+
+func TestOutputProcessor(t *testing.T) {
+	assert.Nil(t, outputProcessor("out.txt", config.OutputRule{}))
+
+	p := outputProcessor("out.json", config.OutputRule{Validate: "json"})
+	_, err := p([]byte("{"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "output out.json is not valid json")
+}